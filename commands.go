@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"backend-demo/conAbi"
+	"backend-demo/storage"
+	"backend-demo/transaction"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// commands are the subcommands of the binary, running it without one runs the demo.
+var commands = map[string]func(args []string) error{
+	"tx-build":     txBuild,
+	"tx-sign":      txSign,
+	"tx-broadcast": txBroadcast,
+	"tx-discard":   txDiscard,
+	"key-new":      keyNew,
+	"key-import":   keyImport,
+	"key-list":     keyList,
+	"key-export":   keyExport,
+	"hd-new":       hdNew,
+	"hd-list":      hdList,
+	"signer-serve": signerServe,
+}
+
+func runCommand(name string, args []string) error {
+	command, ok := commands[name]
+	if !ok {
+		names := make([]string, 0, len(commands))
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown command %q, available: %s", name, strings.Join(names, ", "))
+	}
+	return command(args)
+}
+
+// txBuild builds an unsigned transaction on the online machine. It needs the
+// node for the nonce, the fees and the gas estimate but no key. The nonce is
+// reserved in the state file until the transaction is broadcast with
+// tx-broadcast or given up with tx-discard.
+func txBuild(args []string) error {
+	flags := flag.NewFlagSet("tx-build", flag.ExitOnError)
+	from := flags.String("from", address, "account that will sign the transaction")
+	to := flags.String("to", "", "recipient or contract address, empty to create a contract")
+	data := flags.String("data", "0x", "hex encoded call data")
+	value := flags.String("value", "0", "amount of wei to send")
+	gasLimit := flags.Uint64("gas", 0, "gas limit, 0 to estimate it")
+	description := flags.String("description", "", "description of the transaction")
+	out := flags.String("out", "", "file to write the unsigned transaction to, stdout if empty")
+	flags.Parse(args)
+
+	callData, err := hexutil.Decode(*data)
+	if err != nil {
+		return fmt.Errorf("invalid data: %w", err)
+	}
+	amount, ok := new(big.Int).SetString(*value, 10)
+	if !ok {
+		return fmt.Errorf("invalid value %q", *value)
+	}
+
+	request := &transaction.TxRequest{
+		Data:        callData,
+		GasLimit:    *gasLimit,
+		Value:       amount,
+		Description: *description,
+	}
+	if *to != "" {
+		if !common.IsHexAddress(*to) {
+			return fmt.Errorf("invalid address %q", *to)
+		}
+		recipient := common.HexToAddress(*to)
+		request.To = &recipient
+	}
+
+	store, err := storage.NewFileStore(stateFile)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	conAbi.UseStateStore(store)
+
+	client, err := ethclient.Dial(endPoint)
+	if err != nil {
+		return err
+	}
+
+	chainID, err := client.ChainID(context.Background())
+	if err != nil {
+		return err
+	}
+
+	unsigned, err := conAbi.Build(context.Background(), request, client, common.HexToAddress(*from), chainID)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.MarshalIndent(unsigned, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeOutput(*out, payload)
+}
+
+// txSign signs an unsigned transaction. It does not connect to the network
+// and is meant to run on the machine holding the key.
+func txSign(args []string) error {
+	flags := flag.NewFlagSet("tx-sign", flag.ExitOnError)
+	in := flags.String("in", "", "file with the unsigned transaction")
+	keys := addSignerFlags(flags)
+	out := flags.String("out", "", "file to write the signed transaction to, stdout if empty")
+	flags.Parse(args)
+
+	payload, err := ioutil.ReadFile(*in)
+	if err != nil {
+		return err
+	}
+
+	var unsigned transaction.UnsignedTx
+	if err := json.Unmarshal(payload, &unsigned); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "signing %q from %s with nonce %d on chain %v\n", unsigned.Description, unsigned.From.Hex(), unsigned.Nonce, unsigned.ChainID)
+
+	signer, err := keys.signer(unsigned.From)
+	if err != nil {
+		return err
+	}
+
+	signedTx, err := unsigned.Sign(signer)
+	if err != nil {
+		return err
+	}
+
+	raw, err := signedTx.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return writeOutput(*out, []byte(hexutil.Encode(raw)+"\n"))
+}
+
+// txBroadcast sends a transaction signed with tx-sign and records it in the journal.
+func txBroadcast(args []string) error {
+	flags := flag.NewFlagSet("tx-broadcast", flag.ExitOnError)
+	in := flags.String("in", "", "file with the hex encoded signed transaction")
+	description := flags.String("description", "", "description of the transaction for the journal")
+	wait := flags.Bool("wait", false, "wait until the transaction is mined")
+	flags.Parse(args)
+
+	payload, err := ioutil.ReadFile(*in)
+	if err != nil {
+		return err
+	}
+	raw, err := hexutil.Decode(strings.TrimSpace(string(payload)))
+	if err != nil {
+		return err
+	}
+
+	signedTx := new(types.Transaction)
+	if err := signedTx.UnmarshalBinary(raw); err != nil {
+		return err
+	}
+
+	store, err := storage.NewFileStore(stateFile)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	conAbi.UseStateStore(store)
+
+	client, err := ethclient.Dial(endPoint)
+	if err != nil {
+		return err
+	}
+
+	txHash, err := conAbi.Broadcast(context.Background(), signedTx, *description, client, nil)
+	if err != nil {
+		return err
+	}
+	fmt.Println(txHash.Hex())
+
+	if !*wait {
+		return nil
+	}
+
+	receipt, err := conAbi.WaitMined(context.Background(), txHash, client)
+	if err != nil {
+		return err
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return conAbi.ErrTransactionReverted
+	}
+	return nil
+}
+
+// txDiscard releases the nonce of a transaction built with tx-build which
+// will not be broadcast, so that the next transaction can use it.
+func txDiscard(args []string) error {
+	flags := flag.NewFlagSet("tx-discard", flag.ExitOnError)
+	in := flags.String("in", "", "file with the unsigned transaction")
+	flags.Parse(args)
+
+	payload, err := ioutil.ReadFile(*in)
+	if err != nil {
+		return err
+	}
+
+	var unsigned transaction.UnsignedTx
+	if err := json.Unmarshal(payload, &unsigned); err != nil {
+		return err
+	}
+
+	store, err := storage.NewFileStore(stateFile)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	conAbi.UseStateStore(store)
+
+	client, err := ethclient.Dial(endPoint)
+	if err != nil {
+		return err
+	}
+
+	err = conAbi.Discard(client, &unsigned)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "released nonce %d of %s\n", unsigned.Nonce, unsigned.From.Hex())
+	return nil
+}
+
+// writeOutput writes data to the file path, or to stdout if path is empty.
+func writeOutput(path string, data []byte) error {
+	if path == "" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+// signerServe serves the key of an account over clef's account_* api, a
+// stand-in for clef during development. It signs everything it is asked to.
+func signerServe(args []string) error {
+	flags := flag.NewFlagSet("signer-serve", flag.ExitOnError)
+	listen := flags.String("listen", "127.0.0.1:8550", "address to listen on")
+	account := flags.String("address", address, "account to serve")
+	chainID := flags.Int64("chain-id", 0, "chain id for legacy transactions without one")
+	keys := addSignerFlags(flags)
+	flags.Parse(args)
+
+	if !common.IsHexAddress(*account) {
+		return fmt.Errorf("invalid address %q", *account)
+	}
+
+	signer, err := keys.signer(common.HexToAddress(*account))
+	if err != nil {
+		return err
+	}
+
+	var legacyChainID *big.Int
+	if *chainID != 0 {
+		legacyChainID = big.NewInt(*chainID)
+	}
+
+	service, err := transaction.NewClefService(signer, legacyChainID)
+	if err != nil {
+		return err
+	}
+	server, err := transaction.NewClefServer(service)
+	if err != nil {
+		return err
+	}
+	defer server.Stop()
+
+	log.Printf("serving %s on http://%s", *account, *listen)
+	return http.ListenAndServe(*listen, server)
+}
@@ -3,10 +3,10 @@ package conAbi
 import (
 	"bytes"
 	"context"
+	"backend-demo/storage"
 	"backend-demo/transaction"
 	"errors"
 	"fmt"
-	"log"
 	"math/big"
 	"sync"
 	"time"
@@ -32,9 +32,11 @@ var (
 	oracleABI                          = transaction.ParseABIUnchecked(OracleAbi)
 	errDecodeABI                       = errors.New("err decode abi")
 	ErrTransactionReverted             = errors.New("err transaction reverted")
+	ErrTransactionWouldRevert          = errors.New("err transaction would revert")
 	ErrEventNotFound                   = errors.New("err event not found")
 	ErrInvalidFactory                  = errors.New("invalid factory")
 	ErrNotDeployedByFactory            = errors.New("not deployed by factory")
+	ErrCreate2Failed                   = errors.New("chequebook already deployed for this salt")
 	SenderAdd                          = common.HexToAddress("0xA4E7663A031ca1f67eEa828E4795653504d38c6e")
 	erc20Add                           = common.HexToAddress("0xD26c3d45a805a5f7809E27Bd18949d559e281900")
 	tmpAdd                             = common.HexToAddress("0x9EAA021C41bf7644f68108913DCddd266caaa023")
@@ -45,6 +47,33 @@ var (
 	DebugFlag                   = true
 )
 
+// revertReasons maps the revert reasons of the contracts to errors, see decodeError.
+var revertReasons = map[string]error{
+	"ERC1167: create2 failed": ErrCreate2Failed,
+}
+
+// Settings of Send and WaitMined.
+var (
+	Confirmations       uint64 = 1                // blocks a transaction needs before it is considered final
+	ConfirmationTimeout        = 10 * time.Minute // how long to wait for a transaction to be mined
+	PollingInterval            = 5 * time.Second  // how often to poll the chain while waiting
+	LegacyTransactions         = false            // only send legacy transactions, even on eip1559 chains
+
+	// JournalRetention is how long finished transactions are kept in the
+	// TxJournal, ResumeJournal removes older ones.
+	JournalRetention = 7 * 24 * time.Hour
+
+	// StateStore is where Send persists its state, e.g. the nonces. Use
+	// UseStateStore to change it.
+	StateStore storage.StateStorer = storage.NewMemStore()
+	// TxJournal records every transaction sent by Send.
+	TxJournal = transaction.NewJournal(StateStore)
+	// Monitor replaces stuck transactions sent by Send, nil if disabled.
+	Monitor *transaction.TxMonitor
+
+	nonceManagers = make(map[common.Address]*transaction.NonceManager)
+)
+
 func GetBalance(ctx context.Context, address common.Address, backend transaction.Backend) (*big.Int, error) {
 	callData, err := erc20ABI.Pack("balanceOf", address)
 	if err != nil {
@@ -88,15 +117,9 @@ func Deploy(ctx context.Context, issuer common.Address, defaultHardDepositTimeou
 		return common.Hash{}, err
 	}
 
-	gasPrice, err := backend.SuggestGasPrice(ctx)
-	if err != nil {
-		log.Fatal(err)
-	}
-
 	request := &transaction.TxRequest{
 		To:          &factoryAdd,
 		Data:        callData,
-		GasPrice:    gasPrice,
 		GasLimit:    175000,
 		Value:       big.NewInt(0),
 		Description: "chequebook deployment",
@@ -112,9 +135,7 @@ func Deploy(ctx context.Context, issuer common.Address, defaultHardDepositTimeou
 
 // WaitDeployed waits for the deployment transaction to confirm and returns the chequebook address
 func WaitDeployed(ctx context.Context, txHash common.Hash, backend transaction.Backend) (common.Address, error) {
-	//简单一些，休眠20秒后再查询交易上链
-	time.Sleep(time.Duration(20) * time.Second)
-	receipt, err := backend.TransactionReceipt(ctx, txHash)
+	receipt, err := WaitMined(ctx, txHash, backend)
 	if err != nil {
 		return common.Address{}, fmt.Errorf("contract deployment failed: %w", err)
 	}
@@ -169,15 +190,9 @@ func PreWithdraw(ctx context.Context, fromaddress common.Address, backend transa
 		return common.Hash{}, err
 	}
 
-	gasPrice, err := backend.SuggestGasPrice(ctx)
-	if err != nil {
-		return common.Hash{}, err
-	}
-
 	request := &transaction.TxRequest{
 		To:          &swapAdd,
 		Data:        callData,
-		GasPrice:    gasPrice,
 		GasLimit:    175000,
 		Value:       big.NewInt(0),
 		Description: "pre withdraw",
@@ -225,6 +240,99 @@ func GetWithdrawTime(ctx context.Context, backend transaction.Backend, swapAdd c
 	return withdrawtime, nil
 }
 
+// GetDefaultHardDepositTimeout returns the hard deposit decrease timeout used for beneficiaries without a custom one.
+func GetDefaultHardDepositTimeout(ctx context.Context, backend transaction.Backend, swapAdd common.Address) (*big.Int, error) {
+	callData, err := swapABI.Pack("defaultHardDepositTimeout")
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := Call(ctx, tmpAdd, &transaction.TxRequest{
+		To:   &swapAdd,
+		Data: callData,
+	}, backend)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := swapABI.Unpack("defaultHardDepositTimeout", output)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(results) != 1 {
+		return nil, errDecodeABI
+	}
+
+	timeout, ok := abi.ConvertType(results[0], new(big.Int)).(*big.Int)
+	if !ok || timeout == nil {
+		return nil, errDecodeABI
+	}
+	return timeout, nil
+}
+
+// GetIssuer returns the issuer of the chequebook.
+func GetIssuer(ctx context.Context, backend transaction.Backend, swapAdd common.Address) (common.Address, error) {
+	callData, err := swapABI.Pack("issuer")
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	output, err := Call(ctx, tmpAdd, &transaction.TxRequest{
+		To:   &swapAdd,
+		Data: callData,
+	}, backend)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	results, err := swapABI.Unpack("issuer", output)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	if len(results) != 1 {
+		return common.Address{}, errDecodeABI
+	}
+
+	issuer, ok := abi.ConvertType(results[0], new(common.Address)).(*common.Address)
+	if !ok || issuer == nil {
+		return common.Address{}, errDecodeABI
+	}
+	return *issuer, nil
+}
+
+// GetToken returns the token the chequebook pays out in.
+func GetToken(ctx context.Context, backend transaction.Backend, swapAdd common.Address) (common.Address, error) {
+	callData, err := swapABI.Pack("token")
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	output, err := Call(ctx, tmpAdd, &transaction.TxRequest{
+		To:   &swapAdd,
+		Data: callData,
+	}, backend)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	results, err := swapABI.Unpack("token", output)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	if len(results) != 1 {
+		return common.Address{}, errDecodeABI
+	}
+
+	token, ok := abi.ConvertType(results[0], new(common.Address)).(*common.Address)
+	if !ok || token == nil {
+		return common.Address{}, errDecodeABI
+	}
+	return *token, nil
+}
+
 // VerifyBytecode checks that the factory is valid.
 func VerifyFactoryBytecode(ctx context.Context, backend transaction.Backend) (err error) {
 	code, err := backend.CodeAt(ctx, factoryAdd, nil)
@@ -351,7 +459,7 @@ func GetMasterCopy(ctx context.Context, backend transaction.Backend, proxy commo
 	return *master, nil
 }
 
-//oracle
+// oracle
 func GetOwner(ctx context.Context, backend transaction.Backend, oracle common.Address) (common.Address, error) {
 
 	callData, err := oracleABI.Pack("owner")
@@ -415,58 +523,376 @@ func GetPrice(ctx context.Context, backend transaction.Backend, oracle common.Ad
 }
 
 func Call(ctx context.Context, address common.Address, request *transaction.TxRequest, backend transaction.Backend) ([]byte, error) {
+	return CallAt(ctx, address, request, backend, nil)
+}
+
+// CallAt executes the call against the state at the given block number, or the latest state if blockNumber is nil.
+func CallAt(ctx context.Context, address common.Address, request *transaction.TxRequest, backend transaction.Backend, blockNumber *big.Int) ([]byte, error) {
 	msg := ethereum.CallMsg{
-		From:     address,
-		To:       request.To,
-		Data:     request.Data,
-		GasPrice: request.GasPrice,
-		Gas:      request.GasLimit,
-		Value:    request.Value,
+		From:      address,
+		To:        request.To,
+		Data:      request.Data,
+		GasPrice:  request.GasPrice,
+		GasFeeCap: request.MaxFeePerGas,
+		GasTipCap: request.MaxPriorityFeePerGas,
+		Gas:       request.GasLimit,
+		Value:     request.Value,
 	}
-	data, err := backend.CallContract(ctx, msg, nil)
+	data, err := backend.CallContract(ctx, msg, blockNumber)
 	if err != nil {
-		return nil, err
+		return nil, decodeError(err)
 	}
 
 	return data, nil
 }
 
+// SimulationError is returned by Send when the transaction reverts in the
+// pre-flight simulation. Nothing was signed or broadcast in that case.
+type SimulationError struct {
+	Description string // description of the request
+	Reason      string // revert reason, empty if the contract gave none
+	Err         error  // the decoded revert, a *transaction.RevertError or *transaction.PanicError
+}
+
+func (e *SimulationError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("%s would revert: %v", e.Description, e.Err)
+	}
+	return fmt.Sprintf("%s would revert: %s", e.Description, e.Reason)
+}
+
+func (e *SimulationError) Unwrap() error {
+	return e.Err
+}
+
+// Is makes errors.Is(err, ErrTransactionWouldRevert) work for simulation
+// errors, errors.Is with the error of a known revert reason works through Unwrap.
+func (e *SimulationError) Is(target error) bool {
+	return target == ErrTransactionWouldRevert
+}
+
+// Simulate executes the request from sender with eth_call on the latest block
+// without sending it. It returns a *SimulationError if the execution reverts.
+func Simulate(ctx context.Context, request *transaction.TxRequest, backend transaction.Backend, sender common.Address) error {
+	_, err := backend.CallContract(ctx, ethereum.CallMsg{
+		From:  sender,
+		To:    request.To,
+		Gas:   request.GasLimit,
+		Value: request.Value,
+		Data:  request.Data,
+	}, nil)
+	if err == nil {
+		return nil
+	}
+	if !transaction.IsRevert(err) {
+		return err
+	}
+
+	description := request.Description
+	if description == "" {
+		description = "transaction"
+	}
+	return &SimulationError{
+		Description: description,
+		Reason:      transaction.RevertReason(err),
+		Err:         decodeError(err),
+	}
+}
+
+// decodeError turns revert errors of the node into a *transaction.RevertError
+// or *transaction.PanicError. Revert reasons of our contracts also match their
+// error in revertReasons with errors.Is, e.g. ErrCreate2Failed.
+func decodeError(err error) error {
+	return transaction.DecodeError(err, revertReasons)
+}
+
 // Send creates and signs a transaction based on the request and sends it.
+// The request is simulated first, if it would revert a *SimulationError is
+// returned and nothing is sent.
 func Send(ctx context.Context, request *transaction.TxRequest, backend transaction.Backend, sender common.Address, signer transaction.Signer, chainID *big.Int) (txHash common.Hash, err error) {
-	lock.Lock()
-	defer lock.Unlock()
+	unsigned, err := build(ctx, request, backend, sender, chainID, false)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	defer func() {
+		if err != nil {
+			if releaseErr := Discard(backend, unsigned); releaseErr != nil && DebugFlag {
+				fmt.Println("Send: release nonce failed: ", releaseErr)
+			}
+		}
+	}()
 
-	nonce, err := backend.PendingNonceAt(context.Background(), sender)
+	signedTx, err := unsigned.Sign(signer)
 	if err != nil {
-		fmt.Println("Send err 1")
 		return common.Hash{}, err
 	}
 
-	tx, err := prepareTransaction(ctx, request, sender, backend, nonce)
+	return Broadcast(ctx, signedTx, request.Description, backend, signer)
+}
+
+// Build simulates the request and creates the unsigned transaction for sender
+// with the next nonce, e.g. to be signed offline. The nonce stays reserved
+// until the transaction is passed to Broadcast or Discard. The reservation is
+// kept in the StateStore, so it also holds for later processes using the same
+// store.
+func Build(ctx context.Context, request *transaction.TxRequest, backend transaction.Backend, sender common.Address, chainID *big.Int) (*transaction.UnsignedTx, error) {
+	return build(ctx, request, backend, sender, chainID, true)
+}
+
+// build is Build for transactions signed by Send itself if reserve is false,
+// their nonce is only reserved in memory.
+func build(ctx context.Context, request *transaction.TxRequest, backend transaction.Backend, sender common.Address, chainID *big.Int, reserve bool) (*transaction.UnsignedTx, error) {
+	err := Simulate(ctx, request, backend, sender)
+	if err != nil {
+		if DebugFlag {
+			fmt.Println("Build: simulation failed: ", err)
+		}
+		return nil, err
+	}
+
+	nonces := nonceManager(backend, sender)
+
+	var nonce uint64
+	if reserve {
+		nonce, err = nonces.Reserve(ctx)
+	} else {
+		nonce, err = nonces.Next(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := prepareTransaction(ctx, request, sender, backend, nonce, chainID)
+	if err != nil {
+		if releaseErr := nonces.Release(nonce); releaseErr != nil && DebugFlag {
+			fmt.Println("Build: release nonce failed: ", releaseErr)
+		}
+		return nil, err
+	}
+
+	return &transaction.UnsignedTx{
+		ChainID:     chainID,
+		From:        sender,
+		Nonce:       nonce,
+		Description: request.Description,
+		Tx:          tx,
+	}, nil
+}
+
+// Discard gives back the nonce of a built transaction which will not be sent.
+func Discard(backend transaction.Backend, unsigned *transaction.UnsignedTx) error {
+	return nonceManager(backend, unsigned.From).Release(unsigned.Nonce)
+}
+
+// Broadcast journals and sends a signed transaction. signer is used by the
+// Monitor to replace the transaction if it gets stuck, it may be nil if the
+// key is not available, e.g. for transactions signed offline.
+func Broadcast(ctx context.Context, signedTx *types.Transaction, description string, backend transaction.Backend, signer transaction.Signer) (common.Hash, error) {
+	sender, err := transaction.SignedTxSender(signedTx)
 	if err != nil {
 		return common.Hash{}, err
 	}
 
-	signedTx, err := signer.SignTx(tx, chainID)
+	// journal the transaction before it is out so a crash cannot lose it
+	_, err = TxJournal.Get(signedTx.Hash())
+	if errors.Is(err, transaction.ErrJournalEntryNotFound) {
+		err = TxJournal.Add(sender, requestOf(signedTx, description), signedTx)
+	}
 	if err != nil {
 		return common.Hash{}, err
 	}
 
 	err = backend.SendTransaction(ctx, signedTx)
+	if transaction.IsAlreadyKnown(err) {
+		// the very same transaction was broadcast before, e.g. by a retry
+		err = nil
+	}
 	if err != nil {
+		journalStatus(signedTx.Hash(), transaction.TxStatusFailed, common.Hash{}, err)
+		if transaction.IsNonceTooLow(err) {
+			// the account was used elsewhere, our nonces are out of date
+			if resyncErr := nonceManager(backend, sender).Resync(ctx, signedTx.Nonce()); resyncErr != nil && DebugFlag {
+				fmt.Println("Broadcast: resync nonces failed: ", resyncErr)
+			}
+		}
 		return common.Hash{}, err
 	}
+	journalStatus(signedTx.Hash(), transaction.TxStatusPending, common.Hash{}, nil)
 
-	txHash = signedTx.Hash()
+	// the transaction is out at this point, a failed commit is only logged
+	err = nonceManager(backend, sender).Commit(signedTx.Nonce(), signedTx.Hash())
+	if err != nil && DebugFlag {
+		fmt.Println("Broadcast: commit nonce failed: ", err)
+	}
 
-	//wait for 20 secoonds
-	time.Sleep(time.Duration(20 * time.Second))
+	if Monitor != nil && signer != nil {
+		Monitor.Track(signedTx, sender, signer)
+	}
 
 	return signedTx.Hash(), nil
 }
 
+// requestOf returns the request a transaction would have been built from.
+func requestOf(tx *types.Transaction, description string) *transaction.TxRequest {
+	request := &transaction.TxRequest{
+		To:          tx.To(),
+		Data:        tx.Data(),
+		GasLimit:    tx.Gas(),
+		Value:       tx.Value(),
+		Description: description,
+	}
+	if tx.Type() == types.DynamicFeeTxType {
+		request.MaxFeePerGas = tx.GasFeeCap()
+		request.MaxPriorityFeePerGas = tx.GasTipCap()
+	} else {
+		request.GasPrice = tx.GasPrice()
+	}
+	return request
+}
+
+// UseStateStore makes Send persist nonces and the transaction journal in store.
+// It must be called before the first transaction is sent.
+func UseStateStore(store storage.StateStorer) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	StateStore = store
+	TxJournal = transaction.NewJournal(store)
+	nonceManagers = make(map[common.Address]*transaction.NonceManager)
+}
+
+// nonceManager returns the nonce manager of sender, creating it on first use.
+func nonceManager(backend transaction.Backend, sender common.Address) *transaction.NonceManager {
+	lock.Lock()
+	defer lock.Unlock()
+
+	nonces, ok := nonceManagers[sender]
+	if !ok {
+		nonces = transaction.NewNonceManager(backend, StateStore, sender)
+		nonceManagers[sender] = nonces
+	}
+	return nonces
+}
+
+// WaitMined waits until the transaction is mined with the configured number of Confirmations and returns its receipt.
+// If the Monitor replaced the transaction, the receipt of the replacement that was mined is returned.
+// If the transaction was cancelled with Cancel and the cancellation was mined, ErrCancelled is returned.
+// Transactions the Monitor does not track (anymore) are waited for in all versions known to the TxJournal.
+// If the transaction is not mined within ConfirmationTimeout, an error wrapping transaction.ErrWaitTimeout is returned.
+func WaitMined(ctx context.Context, txHash common.Hash, backend transaction.Backend) (*types.Receipt, error) {
+	receipt, err := waitReceipt(ctx, backend, txHash, []common.Hash{txHash})
+	if err != nil {
+		return nil, err
+	}
+	if isCancel(receipt.TxHash) {
+		return nil, ErrCancelled
+	}
+
+	return receipt, nil
+}
+
+// waitReceipt waits until a version of the transaction txHash is mined with
+// the configured number of Confirmations, bounded by ConfirmationTimeout, and
+// journals its receipt. The versions are followed through the Monitor or the
+// TxJournal, hashes is waited for if neither knows the transaction.
+func waitReceipt(ctx context.Context, backend transaction.Backend, txHash common.Hash, hashes []common.Hash) (*types.Receipt, error) {
+	if ConfirmationTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ConfirmationTimeout)
+		defer cancel()
+	}
+
+	tracked := false
+	if Monitor != nil {
+		receipt, err := Monitor.WaitMined(ctx, txHash)
+		if err == nil {
+			// the monitor only waits for the receipt, the confirmations are counted below
+			hashes = []common.Hash{receipt.TxHash}
+			tracked = true
+		} else if errors.Is(err, transaction.ErrNonceUsed) {
+			journalStatus(txHash, transaction.TxStatusDropped, common.Hash{}, err)
+			return nil, err
+		} else if errors.Is(err, context.DeadlineExceeded) {
+			return nil, fmt.Errorf("%w: %x", transaction.ErrWaitTimeout, txHash)
+		} else if !errors.Is(err, transaction.ErrNotTracked) {
+			return nil, err
+		}
+	}
+	if !tracked {
+		entry, err := TxJournal.Get(txHash)
+		if err == nil {
+			hashes = entry.Hashes()
+		} else if !errors.Is(err, transaction.ErrJournalEntryNotFound) {
+			return nil, err
+		}
+	}
+
+	receipt, err := waitAnyMined(ctx, backend, hashes)
+	if err != nil {
+		return nil, err
+	}
+	journalReceipt(receipt)
+	return receipt, nil
+}
+
+// waitAnyMined waits until one of the transactions is mined and returns its
+// receipt after the configured number of Confirmations. If none is mined
+// within ConfirmationTimeout, an error wrapping transaction.ErrWaitTimeout is
+// returned.
+func waitAnyMined(ctx context.Context, backend transaction.Backend, hashes []common.Hash) (*types.Receipt, error) {
+	if ConfirmationTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ConfirmationTimeout)
+		defer cancel()
+	}
+
+	for {
+		for _, hash := range hashes {
+			receipt, err := backend.TransactionReceipt(ctx, hash)
+			if err != nil {
+				if errors.Is(err, ethereum.NotFound) {
+					continue
+				}
+				return nil, err
+			}
+			if receipt != nil {
+				return transaction.WaitMined(ctx, backend, hash, Confirmations, PollingInterval, 0)
+			}
+		}
+
+		select {
+		case <-time.After(PollingInterval):
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, fmt.Errorf("%w: %x", transaction.ErrWaitTimeout, hashes[len(hashes)-1])
+			}
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// StartTxMonitor starts replacing transactions sent by Send which are not
+// mined within threshold with ones paying higher fees.
+func StartTxMonitor(backend transaction.Backend, threshold time.Duration) *transaction.TxMonitor {
+	Monitor = transaction.NewTxMonitor(backend, threshold, PollingInterval, func(sender common.Address, replaced, replacement *types.Transaction) error {
+		// journal the replacement before it is out so a crash cannot lose it
+		err := journalReplace(replaced.Hash(), replacement)
+		if err != nil {
+			return err
+		}
+		err = nonceManager(backend, sender).Commit(replacement.Nonce(), replacement.Hash())
+		if err != nil && DebugFlag {
+			fmt.Println("StartTxMonitor: commit replacement nonce failed: ", err)
+		}
+		return nil
+	})
+	Monitor.Debug = DebugFlag
+	Monitor.Start()
+	return Monitor
+}
+
 // prepareTransaction creates a signable transaction based on a request.
-func prepareTransaction(ctx context.Context, request *transaction.TxRequest, from common.Address, backend transaction.Backend, nonce uint64) (tx *types.Transaction, err error) {
+func prepareTransaction(ctx context.Context, request *transaction.TxRequest, from common.Address, backend transaction.Backend, nonce uint64, chainID *big.Int) (tx *types.Transaction, err error) {
 	var gasLimit uint64
 	if request.GasLimit == 0 {
 		gasLimit, err = backend.EstimateGas(ctx, ethereum.CallMsg{
@@ -475,7 +901,7 @@ func prepareTransaction(ctx context.Context, request *transaction.TxRequest, fro
 			Data: request.Data,
 		})
 		if err != nil {
-			return nil, err
+			return nil, decodeError(err)
 		}
 
 		gasLimit += gasLimit / 5 // add 20% on top
@@ -484,6 +910,38 @@ func prepareTransaction(ctx context.Context, request *transaction.TxRequest, fro
 		gasLimit = request.GasLimit
 	}
 
+	if request.GasPrice == nil && !LegacyTransactions {
+		gasTipCap, gasFeeCap, err := transaction.SuggestDynamicFees(ctx, backend)
+		if err == nil {
+			if request.MaxPriorityFeePerGas != nil {
+				gasTipCap = request.MaxPriorityFeePerGas
+			}
+			if request.MaxFeePerGas != nil {
+				gasFeeCap = request.MaxFeePerGas
+			} else if gasFeeCap.Cmp(gasTipCap) < 0 {
+				gasFeeCap = gasTipCap
+			}
+			if gasFeeCap.Cmp(gasTipCap) < 0 {
+				return nil, fmt.Errorf("max fee per gas %v lower than max priority fee per gas %v", gasFeeCap, gasTipCap)
+			}
+
+			return types.NewTx(&types.DynamicFeeTx{
+				ChainID:   chainID,
+				Nonce:     nonce,
+				GasTipCap: gasTipCap,
+				GasFeeCap: gasFeeCap,
+				Gas:       gasLimit,
+				To:        request.To,
+				Value:     request.Value,
+				Data:      request.Data,
+			}), nil
+		}
+		if !errors.Is(err, transaction.ErrLondonNotActive) {
+			return nil, err
+		}
+		// chain without eip1559, fall back to a legacy transaction
+	}
+
 	var gasPrice *big.Int
 	if request.GasPrice == nil {
 		gasPrice, err = backend.SuggestGasPrice(ctx)
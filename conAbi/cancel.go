@@ -0,0 +1,108 @@
+package conAbi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"backend-demo/transaction"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var (
+	ErrNotPending  = errors.New("transaction is not pending")
+	ErrWrongSender = errors.New("transaction not sent by this account")
+	// ErrCancelled is returned by WaitMined when a cancellation was mined instead of the transaction.
+	ErrCancelled = errors.New("transaction cancelled")
+)
+
+// CancelResult reports which transaction ended up using the nonce.
+type CancelResult struct {
+	CancelTx  common.Hash
+	Cancelled bool           // true if the cancel transaction was mined, false if the original was
+	Receipt   *types.Receipt // receipt of the transaction that was mined
+}
+
+// Cancel replaces the pending transaction txHash of sender with a zero value
+// transfer to itself with the same nonce and higher fees, then waits until
+// one of them is mined, also if the Monitor bumped the cancellation again.
+// Once the cancellation is mined, WaitMined returns ErrCancelled for txHash.
+func Cancel(ctx context.Context, txHash common.Hash, backend transaction.Backend, sender common.Address, signer transaction.Signer, chainID *big.Int) (*CancelResult, error) {
+	hashes := []common.Hash{txHash}
+	if Monitor != nil {
+		replacements, err := Monitor.Replacements(txHash)
+		if err == nil {
+			hashes = replacements
+		} else if !errors.Is(err, transaction.ErrNotTracked) {
+			return nil, err
+		}
+	}
+
+	// replace the latest version in case the transaction was bumped before
+	tx, isPending, err := backend.TransactionByHash(ctx, hashes[len(hashes)-1])
+	if err != nil {
+		return nil, err
+	}
+	if !isPending {
+		return nil, ErrNotPending
+	}
+
+	from, err := types.Sender(types.LatestSignerForChainID(chainID), tx)
+	if err != nil {
+		return nil, err
+	}
+	if from != sender {
+		return nil, ErrWrongSender
+	}
+
+	cancelTx, err := transaction.ReplaceWith(ctx, backend, tx, &sender, big.NewInt(0), 21000, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	signedTx, err := signer.SignTx(cancelTx, chainID)
+	if err != nil {
+		return nil, err
+	}
+
+	// journal the cancellation before it is out so a crash cannot lose it
+	err = journalCancel(sender, tx, signedTx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = backend.SendTransaction(ctx, signedTx)
+	if err != nil {
+		return nil, err
+	}
+
+	if DebugFlag {
+		fmt.Println("Cancel: cancel tx hash is ", signedTx.Hash())
+	}
+
+	err = nonceManager(backend, sender).Commit(signedTx.Nonce(), signedTx.Hash())
+	if err != nil && DebugFlag {
+		fmt.Println("Cancel: commit nonce failed: ", err)
+	}
+	if Monitor != nil {
+		err = Monitor.AddReplacement(txHash, signedTx)
+		if errors.Is(err, transaction.ErrNotTracked) {
+			Monitor.Track(signedTx, sender, signer)
+		}
+	}
+
+	// the monitor may bump the cancellation again, wait for all its versions
+	receipt, err := waitReceipt(ctx, backend, signedTx.Hash(), append(hashes, signedTx.Hash()))
+	if err != nil {
+		return nil, err
+	}
+
+	return &CancelResult{
+		CancelTx:  signedTx.Hash(),
+		Cancelled: receipt.TxHash == signedTx.Hash() || isCancel(receipt.TxHash),
+		Receipt:   receipt,
+	}, nil
+}
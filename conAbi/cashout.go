@@ -0,0 +1,219 @@
+package conAbi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"backend-demo/transaction"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var (
+	chequeCashedEventType  = swapABI.Events["ChequeCashed"]
+	chequeBouncedEventType = swapABI.Events["ChequeBounced"]
+)
+
+// Cashout is the authorization of a beneficiary for a third party to cash a
+// cheque on its behalf in exchange for callerPayout.
+type Cashout struct {
+	Chequebook    common.Address
+	Sender        common.Address // the account that will submit the cashCheque transaction
+	RequestPayout *big.Int       // the cumulativePayout of the cheque being cashed
+	Recipient     common.Address
+	CallerPayout  *big.Int
+}
+
+// CashoutTypes are the needed type descriptions for cashout signing
+var CashoutTypes = transaction.Types{
+	"EIP712Domain": transaction.EIP712DomainType,
+	"Cashout": []transaction.Type{
+		{
+			Name: "chequebook",
+			Type: "address",
+		},
+		{
+			Name: "sender",
+			Type: "address",
+		},
+		{
+			Name: "requestPayout",
+			Type: "uint256",
+		},
+		{
+			Name: "recipient",
+			Type: "address",
+		},
+		{
+			Name: "callerPayout",
+			Type: "uint256",
+		},
+	},
+}
+
+// cashoutTypedData builds the eip712 typed data of a cashout authorization.
+func cashoutTypedData(cashout *Cashout, chainID *big.Int) *transaction.TypedData {
+	return &transaction.TypedData{
+		Domain: eip712DomainForChequebook(chainID),
+		Types:  CashoutTypes,
+		Message: transaction.TypedDataMessage{
+			"chequebook":    cashout.Chequebook.Hex(),
+			"sender":        cashout.Sender.Hex(),
+			"requestPayout": cashout.RequestPayout.String(),
+			"recipient":     cashout.Recipient.Hex(),
+			"callerPayout":  cashout.CallerPayout.String(),
+		},
+		PrimaryType: "Cashout",
+	}
+}
+
+// SignCashout signs the cashout authorization with the beneficiary's signer.
+func SignCashout(cashout *Cashout, signer transaction.Signer, chainID *big.Int) ([]byte, error) {
+	if cashout.RequestPayout == nil || cashout.CallerPayout == nil {
+		return nil, errors.New("invalid cashout payout")
+	}
+	if cashout.CallerPayout.Cmp(cashout.RequestPayout) > 0 {
+		return nil, errors.New("caller payout exceeds cheque payout")
+	}
+
+	typedData := cashoutTypedData(cashout, chainID)
+	return signer.SignTypedData(typedData.Domain, typedData.Types, typedData.PrimaryType, typedData.Message)
+}
+
+// RecoverCashoutSigner recovers the address that signed the cashout authorization.
+func RecoverCashoutSigner(cashout *Cashout, signature []byte, chainID *big.Int) (common.Address, error) {
+	return transaction.RecoverTypedDataAddress(signature, cashoutTypedData(cashout, chainID))
+}
+
+// CashChequeResult is the outcome of a cashout transaction.
+type CashChequeResult struct {
+	TxHash           common.Hash
+	Beneficiary      common.Address
+	Recipient        common.Address
+	Caller           common.Address
+	TotalPayout      *big.Int // amount actually paid out in this transaction
+	CumulativePayout *big.Int
+	CallerPayout     *big.Int
+	Bounced          bool // true if the chequebook could not cover the cheque
+}
+
+type chequeCashedEvent struct {
+	Beneficiary      common.Address
+	Recipient        common.Address
+	Caller           common.Address
+	TotalPayout      *big.Int
+	CumulativePayout *big.Int
+	CallerPayout     *big.Int
+}
+
+// CashCheque cashes the cheque as its beneficiary through cashChequeBeneficiary,
+// sending the tokens to recipient, and waits for the transaction to be mined.
+func CashCheque(ctx context.Context, backend transaction.Backend, beneficiary common.Address, recipient common.Address, cheque *SignedCheque, signer transaction.Signer, chainID *big.Int) (*CashChequeResult, error) {
+	callData, err := swapABI.Pack("cashChequeBeneficiary", recipient, cheque.CumulativePayout, cheque.Signature)
+	if err != nil {
+		return nil, err
+	}
+
+	request := &transaction.TxRequest{
+		To:          &cheque.Chequebook,
+		Data:        callData,
+		Value:       big.NewInt(0),
+		Description: "cheque cashout",
+	}
+
+	txHash, err := Send(ctx, request, backend, beneficiary, signer, chainID)
+	if err != nil {
+		return nil, err
+	}
+
+	if DebugFlag {
+		fmt.Println("CashCheque: tx hash is ", txHash)
+	}
+
+	receipt, err := WaitMined(ctx, txHash, backend)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseCashChequeReceipt(receipt, cheque.Chequebook)
+}
+
+// CashChequeFor cashes the cheque on behalf of its beneficiary through
+// cashCheque. The caller is the relayer account submitting the transaction
+// and receives callerPayout, the remaining payout goes to recipient.
+// beneficiarySig is the beneficiary's signature over the matching Cashout.
+func CashChequeFor(ctx context.Context, backend transaction.Backend, caller common.Address, recipient common.Address, cheque *SignedCheque, callerPayout *big.Int, beneficiarySig []byte, signer transaction.Signer, chainID *big.Int) (*CashChequeResult, error) {
+	beneficiary, err := RecoverCashoutSigner(&Cashout{
+		Chequebook:    cheque.Chequebook,
+		Sender:        caller,
+		RequestPayout: cheque.CumulativePayout,
+		Recipient:     recipient,
+		CallerPayout:  callerPayout,
+	}, beneficiarySig, chainID)
+	if err != nil {
+		return nil, err
+	}
+	if beneficiary != cheque.Beneficiary {
+		return nil, ErrWrongBeneficiary
+	}
+
+	callData, err := swapABI.Pack("cashCheque", cheque.Beneficiary, recipient, cheque.CumulativePayout, beneficiarySig, callerPayout, cheque.Signature)
+	if err != nil {
+		return nil, err
+	}
+
+	request := &transaction.TxRequest{
+		To:          &cheque.Chequebook,
+		Data:        callData,
+		Value:       big.NewInt(0),
+		Description: "cheque cashout for beneficiary",
+	}
+
+	txHash, err := Send(ctx, request, backend, caller, signer, chainID)
+	if err != nil {
+		return nil, err
+	}
+
+	if DebugFlag {
+		fmt.Println("CashChequeFor: tx hash is ", txHash)
+	}
+
+	receipt, err := WaitMined(ctx, txHash, backend)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseCashChequeReceipt(receipt, cheque.Chequebook)
+}
+
+// parseCashChequeReceipt extracts the ChequeCashed and ChequeBounced events of a cashout receipt.
+func parseCashChequeReceipt(receipt *types.Receipt, chequebook common.Address) (*CashChequeResult, error) {
+	var cashed chequeCashedEvent
+	err := FindSingleEvent(&swapABI, receipt, chequebook, chequeCashedEventType, &cashed)
+	if err != nil {
+		return nil, fmt.Errorf("cheque cashout failed: %w", err)
+	}
+
+	result := &CashChequeResult{
+		TxHash:           receipt.TxHash,
+		Beneficiary:      cashed.Beneficiary,
+		Recipient:        cashed.Recipient,
+		Caller:           cashed.Caller,
+		TotalPayout:      cashed.TotalPayout,
+		CumulativePayout: cashed.CumulativePayout,
+		CallerPayout:     cashed.CallerPayout,
+	}
+
+	var bounced struct{}
+	err = FindSingleEvent(&swapABI, receipt, chequebook, chequeBouncedEventType, &bounced)
+	if err == nil {
+		result.Bounced = true
+	} else if !errors.Is(err, ErrEventNotFound) {
+		return nil, err
+	}
+
+	return result, nil
+}
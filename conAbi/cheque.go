@@ -0,0 +1,186 @@
+package conAbi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"backend-demo/transaction"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+)
+
+// Cheque represents a cheque for a SimpleSwap chequebook
+type Cheque struct {
+	Chequebook       common.Address
+	Beneficiary      common.Address
+	CumulativePayout *big.Int
+}
+
+// SignedCheque represents a cheque together with its signature
+type SignedCheque struct {
+	Cheque
+	Signature []byte
+}
+
+var (
+	ErrChequeInvalid       = errors.New("invalid cheque")
+	ErrChequeNotIncreasing = errors.New("cheque cumulativePayout is not increasing")
+	ErrWrongBeneficiary    = errors.New("wrong beneficiary")
+	ErrWrongToken          = errors.New("chequebook uses wrong token")
+)
+
+// chequebookDomainName and chequebookDomainVersion are the eip712 domain
+// values hardcoded in the ERC20SimpleSwap contract.
+const (
+	chequebookDomainName    = "Chequebook"
+	chequebookDomainVersion = "1.0"
+)
+
+// ChequeTypes are the needed type descriptions for cheque signing
+var ChequeTypes = transaction.Types{
+	"EIP712Domain": transaction.EIP712DomainType,
+	"Cheque": []transaction.Type{
+		{
+			Name: "chequebook",
+			Type: "address",
+		},
+		{
+			Name: "beneficiary",
+			Type: "address",
+		},
+		{
+			Name: "cumulativePayout",
+			Type: "uint256",
+		},
+	},
+}
+
+// eip712DomainForChequebook returns the eip712 domain the chequebook contract uses on the given chain.
+func eip712DomainForChequebook(chainID *big.Int) transaction.TypedDataDomain {
+	return transaction.TypedDataDomain{
+		Name:    chequebookDomainName,
+		Version: chequebookDomainVersion,
+		ChainId: math.NewHexOrDecimal256(chainID.Int64()),
+	}
+}
+
+// chequeTypedData builds the eip712 typed data of a cheque.
+func chequeTypedData(cheque *Cheque, chainID *big.Int) *transaction.TypedData {
+	return &transaction.TypedData{
+		Domain: eip712DomainForChequebook(chainID),
+		Types:  ChequeTypes,
+		Message: transaction.TypedDataMessage{
+			"chequebook":       cheque.Chequebook.Hex(),
+			"beneficiary":      cheque.Beneficiary.Hex(),
+			"cumulativePayout": cheque.CumulativePayout.String(),
+		},
+		PrimaryType: "Cheque",
+	}
+}
+
+// ChequeDigest returns the eip712 digest of the cheque which the issuer has to sign.
+func ChequeDigest(cheque *Cheque, chainID *big.Int) ([]byte, error) {
+	return transaction.HashTypedData(chequeTypedData(cheque, chainID))
+}
+
+// SignCheque signs the cheque with the issuer's signer and returns the signed cheque.
+func SignCheque(cheque *Cheque, signer transaction.Signer, chainID *big.Int) (*SignedCheque, error) {
+	if cheque.CumulativePayout == nil || cheque.CumulativePayout.Sign() < 0 {
+		return nil, fmt.Errorf("invalid cumulative payout %v", cheque.CumulativePayout)
+	}
+
+	typedData := chequeTypedData(cheque, chainID)
+	signature, err := signer.SignTypedData(typedData.Domain, typedData.Types, typedData.PrimaryType, typedData.Message)
+	if err != nil {
+		return nil, err
+	}
+	if DebugFlag {
+		fmt.Println("SignCheque: signature is ", common.Bytes2Hex(signature))
+	}
+
+	return &SignedCheque{
+		Cheque:    *cheque,
+		Signature: signature,
+	}, nil
+}
+
+// Equal checks if two cheques are the same
+func (cheque *Cheque) Equal(other *Cheque) bool {
+	if cheque.Beneficiary != other.Beneficiary {
+		return false
+	}
+	if cheque.Chequebook != other.Chequebook {
+		return false
+	}
+	if cheque.CumulativePayout == nil || other.CumulativePayout == nil {
+		return cheque.CumulativePayout == nil && other.CumulativePayout == nil
+	}
+	return cheque.CumulativePayout.Cmp(other.CumulativePayout) == 0
+}
+
+// RecoverChequeIssuer recovers the address that signed the cheque.
+func RecoverChequeIssuer(cheque *SignedCheque, chainID *big.Int) (common.Address, error) {
+	return transaction.RecoverTypedDataAddress(cheque.Signature, chequeTypedData(&cheque.Cheque, chainID))
+}
+
+// VerifyCheque checks a cheque received for beneficiary. It verifies that the
+// cheque was signed by the issuer of the chequebook, that the chequebook was
+// deployed by the factory and uses the factory's token, and that the
+// cumulativePayout is higher than lastCumulativePayout (nil if no cheque was
+// received before). It returns the amount the cheque adds.
+func VerifyCheque(ctx context.Context, backend transaction.Backend, cheque *SignedCheque, beneficiary common.Address, lastCumulativePayout *big.Int, chainID *big.Int) (*big.Int, error) {
+	if cheque == nil || cheque.CumulativePayout == nil {
+		return nil, ErrChequeInvalid
+	}
+
+	if cheque.Beneficiary != beneficiary {
+		return nil, ErrWrongBeneficiary
+	}
+
+	if lastCumulativePayout == nil {
+		lastCumulativePayout = big.NewInt(0)
+	}
+	amount := new(big.Int).Sub(cheque.CumulativePayout, lastCumulativePayout)
+	if amount.Sign() <= 0 {
+		return nil, ErrChequeNotIncreasing
+	}
+
+	issuer, err := RecoverChequeIssuer(cheque, chainID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrChequeInvalid, err)
+	}
+
+	err = VerifyChequebook(ctx, cheque.Chequebook, backend)
+	if err != nil {
+		return nil, err
+	}
+
+	chequebookIssuer, err := GetIssuer(ctx, backend, cheque.Chequebook)
+	if err != nil {
+		return nil, err
+	}
+	if issuer != chequebookIssuer {
+		return nil, ErrChequeInvalid
+	}
+
+	token, err := GetToken(ctx, backend, cheque.Chequebook)
+	if err != nil {
+		return nil, err
+	}
+	factoryToken, err := ERC20Address(ctx, backend)
+	if err != nil {
+		return nil, err
+	}
+	if token != factoryToken {
+		return nil, ErrWrongToken
+	}
+
+	if DebugFlag {
+		fmt.Println("VerifyCheque: cheque from ", issuer, " adds ", amount)
+	}
+
+	return amount, nil
+}
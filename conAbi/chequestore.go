@@ -0,0 +1,179 @@
+package conAbi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"backend-demo/storage"
+	"backend-demo/transaction"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	sentChequePrefix     = "cheque_sent_"
+	receivedChequePrefix = "cheque_received_"
+)
+
+var (
+	ErrNoCheque = errors.New("no cheque")
+)
+
+// ChequeStore keeps the last sent and last received cheque per chequebook and beneficiary.
+type ChequeStore struct {
+	lock  sync.Mutex
+	store storage.StateStorer
+}
+
+// NewChequeStore creates a cheque store on top of the given state store.
+func NewChequeStore(store storage.StateStorer) *ChequeStore {
+	return &ChequeStore{
+		store: store,
+	}
+}
+
+func chequeKey(prefix string, chequebook, beneficiary common.Address) string {
+	return fmt.Sprintf("%s%x_%x", prefix, chequebook, beneficiary)
+}
+
+// LastSentCheque returns the last cheque we issued from chequebook to beneficiary.
+func (s *ChequeStore) LastSentCheque(chequebook, beneficiary common.Address) (*SignedCheque, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.get(chequeKey(sentChequePrefix, chequebook, beneficiary))
+}
+
+// LastReceivedCheque returns the last cheque we received from chequebook for beneficiary.
+func (s *ChequeStore) LastReceivedCheque(chequebook, beneficiary common.Address) (*SignedCheque, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.get(chequeKey(receivedChequePrefix, chequebook, beneficiary))
+}
+
+// PutSentCheque stores a cheque we issued. It fails if the cumulativePayout
+// is not higher than the one of the last sent cheque.
+func (s *ChequeStore) PutSentCheque(cheque *SignedCheque) error {
+	if cheque == nil || cheque.CumulativePayout == nil {
+		return ErrChequeInvalid
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.put(chequeKey(sentChequePrefix, cheque.Chequebook, cheque.Beneficiary), cheque)
+}
+
+// PutReceivedCheque stores a cheque we received. It fails if the
+// cumulativePayout is not higher than the one of the last received cheque.
+func (s *ChequeStore) PutReceivedCheque(cheque *SignedCheque) error {
+	if cheque == nil || cheque.CumulativePayout == nil {
+		return ErrChequeInvalid
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.put(chequeKey(receivedChequePrefix, cheque.Chequebook, cheque.Beneficiary), cheque)
+}
+
+// IssueCheque signs a new cheque increasing the cumulativePayout to
+// beneficiary by amount and stores it as the last sent cheque.
+func (s *ChequeStore) IssueCheque(chequebook, beneficiary common.Address, amount *big.Int, signer transaction.Signer, chainID *big.Int) (*SignedCheque, error) {
+	if amount == nil || amount.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid cheque amount %v", amount)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	key := chequeKey(sentChequePrefix, chequebook, beneficiary)
+	cumulativePayout := new(big.Int).Set(amount)
+	last, err := s.get(key)
+	if err != nil {
+		if !errors.Is(err, ErrNoCheque) {
+			return nil, err
+		}
+	} else {
+		cumulativePayout.Add(cumulativePayout, last.CumulativePayout)
+	}
+
+	cheque, err := SignCheque(&Cheque{
+		Chequebook:       chequebook,
+		Beneficiary:      beneficiary,
+		CumulativePayout: cumulativePayout,
+	}, signer, chainID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.put(key, cheque)
+	if err != nil {
+		return nil, err
+	}
+
+	return cheque, nil
+}
+
+// ReceiveCheque verifies a received cheque against the last received one and
+// stores it. It returns the amount the cheque adds.
+func (s *ChequeStore) ReceiveCheque(ctx context.Context, backend transaction.Backend, cheque *SignedCheque, beneficiary common.Address, chainID *big.Int) (*big.Int, error) {
+	if cheque == nil {
+		return nil, ErrChequeInvalid
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	key := chequeKey(receivedChequePrefix, cheque.Chequebook, cheque.Beneficiary)
+	lastCumulativePayout := big.NewInt(0)
+	last, err := s.get(key)
+	if err != nil {
+		if !errors.Is(err, ErrNoCheque) {
+			return nil, err
+		}
+	} else {
+		lastCumulativePayout = last.CumulativePayout
+	}
+
+	amount, err := VerifyCheque(ctx, backend, cheque, beneficiary, lastCumulativePayout, chainID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.put(key, cheque)
+	if err != nil {
+		return nil, err
+	}
+
+	return amount, nil
+}
+
+func (s *ChequeStore) get(key string) (*SignedCheque, error) {
+	var cheque SignedCheque
+	err := s.store.Get(key, &cheque)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, ErrNoCheque
+		}
+		return nil, err
+	}
+	return &cheque, nil
+}
+
+func (s *ChequeStore) put(key string, cheque *SignedCheque) error {
+	last, err := s.get(key)
+	if err != nil {
+		if !errors.Is(err, ErrNoCheque) {
+			return err
+		}
+	} else if cheque.CumulativePayout.Cmp(last.CumulativePayout) <= 0 {
+		return ErrChequeNotIncreasing
+	}
+
+	return s.store.Put(key, cheque)
+}
@@ -0,0 +1,161 @@
+package conAbi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"backend-demo/transaction"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	// eip1167Prefix and eip1167Suffix surround the master address in the
+	// minimal proxy init code the factory deploys with CREATE2.
+	eip1167Prefix = common.FromHex("3d602d80600a3d3981f3363d3d373d3d3d363d73")
+	eip1167Suffix = common.FromHex("5af43d82803e903d91602b57fd5bf3")
+)
+
+// GetMaster returns the chequebook implementation the factory clones.
+func GetMaster(ctx context.Context, backend transaction.Backend) (common.Address, error) {
+	var master common.Address
+	callData, err := swapFactoryABI.Pack("master")
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	output, err := Call(ctx, tmpAdd, &transaction.TxRequest{
+		To:   &factoryAdd,
+		Data: callData,
+	}, backend)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	err = swapFactoryABI.UnpackIntoInterface(&master, "master", output)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return master, nil
+}
+
+// ChequebookSalt returns the CREATE2 salt the factory uses for a chequebook.
+// The factory derives it from the account sending deploySimpleSwap (msg.sender,
+// the issuer when using Deploy) and the salt passed to it as
+// keccak256(abi.encode(deployer, salt)).
+func ChequebookSalt(deployer common.Address, salt common.Hash) common.Hash {
+	return crypto.Keccak256Hash(common.LeftPadBytes(deployer.Bytes(), 32), salt.Bytes())
+}
+
+// ComputeChequebookAddress computes the address of the EIP-1167 clone of
+// master deployed by the factory for deployer and salt.
+func ComputeChequebookAddress(master common.Address, deployer common.Address, salt common.Hash) common.Address {
+	initCode := make([]byte, 0, len(eip1167Prefix)+AddressLength+len(eip1167Suffix))
+	initCode = append(initCode, eip1167Prefix...)
+	initCode = append(initCode, master.Bytes()...)
+	initCode = append(initCode, eip1167Suffix...)
+
+	return crypto.CreateAddress2(factoryAdd, ChequebookSalt(deployer, salt), crypto.Keccak256(initCode))
+}
+
+// PredictChequebookAddress returns the address the chequebook deployed by
+// deployer with salt will have, before the deployment is sent.
+func PredictChequebookAddress(ctx context.Context, backend transaction.Backend, deployer common.Address, salt common.Hash) (common.Address, error) {
+	master, err := GetMaster(ctx, backend)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	chequebook := ComputeChequebookAddress(master, deployer, salt)
+	if DebugFlag {
+		fmt.Println("PredictChequebookAddress: chequebook address is ", chequebook)
+	}
+	return chequebook, nil
+}
+
+// EnsureDeployed deploys the chequebook of issuer for salt unless it already
+// exists and returns its address. It is safe to call again after a crash
+// between sending the deployment and waiting for it: a deployment still
+// pending in the TxJournal is waited for and the predicted address is checked
+// with the factory, so no second deployment is sent for the same salt. This
+// needs a persisted journal (UseStateStore). If the pending deployment is not
+// mined within ConfirmationTimeout, an error wrapping transaction.ErrWaitTimeout
+// is returned.
+func EnsureDeployed(ctx context.Context, issuer common.Address, defaultHardDepositTimeoutDuration *big.Int, salt common.Hash, backend transaction.Backend, signer transaction.Signer, chainID *big.Int) (common.Address, error) {
+	chequebook, err := PredictChequebookAddress(ctx, backend, issuer, salt)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	// a deployment from a previous run might still be pending
+	err = waitJournaledDeployment(ctx, backend, issuer, defaultHardDepositTimeoutDuration, salt)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	deployed, err := verifyChequebookAgainstFactory(ctx, chequebook, backend)
+	if err != nil {
+		return common.Address{}, err
+	}
+	if deployed {
+		if DebugFlag {
+			fmt.Println("EnsureDeployed: chequebook already deployed at ", chequebook)
+		}
+		return chequebook, nil
+	}
+
+	txHash, err := Deploy(ctx, issuer, defaultHardDepositTimeoutDuration, salt, backend, signer, chainID)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	deployedAddress, err := WaitDeployed(ctx, txHash, backend)
+	if err != nil {
+		return common.Address{}, err
+	}
+	if deployedAddress != chequebook {
+		return common.Address{}, fmt.Errorf("chequebook deployed at %x, expected %x", deployedAddress, chequebook)
+	}
+
+	return chequebook, nil
+}
+
+// waitJournaledDeployment waits for the deployments of the chequebook of
+// issuer for salt which the TxJournal knows as pending, e.g. because an
+// earlier run crashed before they were mined. Other pending transactions of
+// issuer are not waited for. Each wait is bounded by ConfirmationTimeout.
+func waitJournaledDeployment(ctx context.Context, backend transaction.Backend, issuer common.Address, defaultHardDepositTimeoutDuration *big.Int, salt common.Hash) error {
+	callData, err := swapFactoryABI.Pack("deploySimpleSwap", issuer, big.NewInt(0).Set(defaultHardDepositTimeoutDuration), salt)
+	if err != nil {
+		return err
+	}
+
+	entries, err := TxJournal.Unfinished()
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.Status != transaction.TxStatusPending || entry.Sender != issuer {
+			continue
+		}
+		if entry.Request.To == nil || *entry.Request.To != factoryAdd || !bytes.Equal(entry.Request.Data, callData) {
+			continue
+		}
+
+		if DebugFlag {
+			fmt.Println("waitJournaledDeployment: waiting for deployment ", entry.Hash)
+		}
+
+		// whether a cancelled or dropped deployment left a chequebook is checked with the factory afterwards
+		_, err := WaitMined(ctx, entry.Hash, backend)
+		if err != nil && !errors.Is(err, ErrCancelled) && !errors.Is(err, transaction.ErrNonceUsed) {
+			return err
+		}
+	}
+	return nil
+}
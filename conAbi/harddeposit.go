@@ -0,0 +1,269 @@
+package conAbi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"backend-demo/transaction"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	ErrNoDecreasePrepared      = errors.New("no hard deposit decrease prepared")
+	ErrInvalidTimeoutSignature = errors.New("invalid custom hard deposit timeout signature")
+	ErrTimeoutMismatch         = errors.New("hard deposit timeout does not match")
+	chainTimePollInterval      = 15 * time.Second
+)
+
+// HardDeposit is the hard deposit an issuer holds for a beneficiary, as stored in the chequebook.
+type HardDeposit struct {
+	Amount           *big.Int // amount reserved for the beneficiary
+	DecreaseAmount   *big.Int // amount the deposit will be decreased by once prepared
+	Timeout          *big.Int // custom decrease timeout, 0 if the default is used
+	CanBeDecreasedAt *big.Int // unix time after which the decrease can be executed, 0 if none prepared
+}
+
+// GetHardDeposit reads the hard deposit of beneficiary from the chequebook.
+func GetHardDeposit(ctx context.Context, backend transaction.Backend, swapAdd common.Address, beneficiary common.Address) (*HardDeposit, error) {
+	callData, err := swapABI.Pack("hardDeposits", beneficiary)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := Call(ctx, tmpAdd, &transaction.TxRequest{
+		To:   &swapAdd,
+		Data: callData,
+	}, backend)
+	if err != nil {
+		return nil, err
+	}
+
+	var hardDeposit HardDeposit
+	err = swapABI.UnpackIntoInterface(&hardDeposit, "hardDeposits", output)
+	if err != nil {
+		return nil, err
+	}
+	if hardDeposit.Amount == nil || hardDeposit.DecreaseAmount == nil || hardDeposit.Timeout == nil || hardDeposit.CanBeDecreasedAt == nil {
+		return nil, errDecodeABI
+	}
+
+	return &hardDeposit, nil
+}
+
+// IncreaseHardDeposit reserves amount of the chequebook balance for beneficiary.
+func IncreaseHardDeposit(ctx context.Context, backend transaction.Backend, issuer common.Address, swapAdd common.Address, beneficiary common.Address, amount *big.Int, signer transaction.Signer, chainID *big.Int) (common.Hash, error) {
+	callData, err := swapABI.Pack("increaseHardDeposit", beneficiary, amount)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	request := &transaction.TxRequest{
+		To:          &swapAdd,
+		Data:        callData,
+		Value:       big.NewInt(0),
+		Description: "increase hard deposit",
+	}
+
+	return Send(ctx, request, backend, issuer, signer, chainID)
+}
+
+// PrepareDecreaseHardDeposit announces a decrease of the hard deposit of
+// beneficiary. The decrease can be executed once the timeout has passed.
+func PrepareDecreaseHardDeposit(ctx context.Context, backend transaction.Backend, issuer common.Address, swapAdd common.Address, beneficiary common.Address, decreaseAmount *big.Int, signer transaction.Signer, chainID *big.Int) (common.Hash, error) {
+	callData, err := swapABI.Pack("prepareDecreaseHardDeposit", beneficiary, decreaseAmount)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	request := &transaction.TxRequest{
+		To:          &swapAdd,
+		Data:        callData,
+		Value:       big.NewInt(0),
+		Description: "prepare decrease hard deposit",
+	}
+
+	return Send(ctx, request, backend, issuer, signer, chainID)
+}
+
+// DecreaseHardDeposit executes a previously prepared decrease of the hard deposit of beneficiary.
+func DecreaseHardDeposit(ctx context.Context, backend transaction.Backend, issuer common.Address, swapAdd common.Address, beneficiary common.Address, signer transaction.Signer, chainID *big.Int) (common.Hash, error) {
+	callData, err := swapABI.Pack("decreaseHardDeposit", beneficiary)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	request := &transaction.TxRequest{
+		To:          &swapAdd,
+		Data:        callData,
+		Value:       big.NewInt(0),
+		Description: "decrease hard deposit",
+	}
+
+	return Send(ctx, request, backend, issuer, signer, chainID)
+}
+
+// DecreaseHardDepositWhenDue waits until the prepared decrease of the hard
+// deposit of beneficiary can be executed and then submits it. It blocks until
+// the transaction is sent or ctx is done, so callers usually run it in a
+// goroutine.
+func DecreaseHardDepositWhenDue(ctx context.Context, backend transaction.Backend, issuer common.Address, swapAdd common.Address, beneficiary common.Address, signer transaction.Signer, chainID *big.Int) (common.Hash, error) {
+	hardDeposit, err := GetHardDeposit(ctx, backend, swapAdd, beneficiary)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if hardDeposit.CanBeDecreasedAt.Sign() == 0 {
+		return common.Hash{}, ErrNoDecreasePrepared
+	}
+
+	if DebugFlag {
+		fmt.Println("DecreaseHardDepositWhenDue: waiting until ", time.Unix(hardDeposit.CanBeDecreasedAt.Int64(), 0))
+	}
+
+	err = waitChainTime(ctx, backend, hardDeposit.CanBeDecreasedAt)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	return DecreaseHardDeposit(ctx, backend, issuer, swapAdd, beneficiary, signer, chainID)
+}
+
+// CustomDecreaseTimeoutTypes are the needed type descriptions for signing a custom hard deposit timeout
+var CustomDecreaseTimeoutTypes = transaction.Types{
+	"EIP712Domain": transaction.EIP712DomainType,
+	"CustomDecreaseTimeout": []transaction.Type{
+		{
+			Name: "chequebook",
+			Type: "address",
+		},
+		{
+			Name: "beneficiary",
+			Type: "address",
+		},
+		{
+			Name: "decreaseTimeout",
+			Type: "uint256",
+		},
+	},
+}
+
+// customDecreaseTimeoutTypedData builds the eip712 typed data of a custom hard deposit timeout.
+func customDecreaseTimeoutTypedData(swapAdd common.Address, beneficiary common.Address, timeout *big.Int, chainID *big.Int) *transaction.TypedData {
+	return &transaction.TypedData{
+		Domain: eip712DomainForChequebook(chainID),
+		Types:  CustomDecreaseTimeoutTypes,
+		Message: transaction.TypedDataMessage{
+			"chequebook":      swapAdd.Hex(),
+			"beneficiary":     beneficiary.Hex(),
+			"decreaseTimeout": timeout.String(),
+		},
+		PrimaryType: "CustomDecreaseTimeout",
+	}
+}
+
+// SignCustomDecreaseTimeout signs, as beneficiary, the agreement to a custom
+// hard deposit decrease timeout in the given chequebook.
+func SignCustomDecreaseTimeout(swapAdd common.Address, beneficiary common.Address, timeout *big.Int, signer transaction.Signer, chainID *big.Int) ([]byte, error) {
+	if timeout == nil || timeout.Sign() < 0 {
+		return nil, fmt.Errorf("invalid hard deposit timeout %v", timeout)
+	}
+
+	typedData := customDecreaseTimeoutTypedData(swapAdd, beneficiary, timeout, chainID)
+	return signer.SignTypedData(typedData.Domain, typedData.Types, typedData.PrimaryType, typedData.Message)
+}
+
+// VerifyCustomDecreaseTimeout checks that beneficiarySig is the beneficiary's
+// signature agreeing to timeout in the given chequebook.
+func VerifyCustomDecreaseTimeout(swapAdd common.Address, beneficiary common.Address, timeout *big.Int, beneficiarySig []byte, chainID *big.Int) error {
+	recovered, err := transaction.RecoverTypedDataAddress(beneficiarySig, customDecreaseTimeoutTypedData(swapAdd, beneficiary, timeout, chainID))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTimeoutSignature, err)
+	}
+
+	if recovered != beneficiary {
+		return ErrInvalidTimeoutSignature
+	}
+	return nil
+}
+
+// SetCustomHardDepositTimeout submits, as issuer, the custom hard deposit
+// timeout the beneficiary agreed to with beneficiarySig.
+func SetCustomHardDepositTimeout(ctx context.Context, backend transaction.Backend, issuer common.Address, swapAdd common.Address, beneficiary common.Address, timeout *big.Int, beneficiarySig []byte, signer transaction.Signer, chainID *big.Int) (common.Hash, error) {
+	err := VerifyCustomDecreaseTimeout(swapAdd, beneficiary, timeout, beneficiarySig, chainID)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	callData, err := swapABI.Pack("setCustomHardDepositTimeout", beneficiary, timeout, beneficiarySig)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	request := &transaction.TxRequest{
+		To:          &swapAdd,
+		Data:        callData,
+		Value:       big.NewInt(0),
+		Description: "set custom hard deposit timeout",
+	}
+
+	return Send(ctx, request, backend, issuer, signer, chainID)
+}
+
+// VerifyHardDepositTimeout checks, as beneficiary, that the chequebook applies
+// the agreed timeout to our hard deposit.
+func VerifyHardDepositTimeout(ctx context.Context, backend transaction.Backend, swapAdd common.Address, beneficiary common.Address, timeout *big.Int) error {
+	hardDeposit, err := GetHardDeposit(ctx, backend, swapAdd, beneficiary)
+	if err != nil {
+		return err
+	}
+
+	effective := hardDeposit.Timeout
+	if effective.Sign() == 0 {
+		effective, err = GetDefaultHardDepositTimeout(ctx, backend, swapAdd)
+		if err != nil {
+			return err
+		}
+	}
+
+	if effective.Cmp(timeout) != 0 {
+		return fmt.Errorf("%w: expected %v, got %v", ErrTimeoutMismatch, timeout, effective)
+	}
+	return nil
+}
+
+// chainTime returns the timestamp of the latest block.
+func chainTime(ctx context.Context, backend transaction.Backend) (*big.Int, error) {
+	header, err := backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetUint64(header.Time), nil
+}
+
+// waitChainTime waits until the latest block has a timestamp of at least t,
+// so that a transaction mined next sees block.timestamp > t.
+func waitChainTime(ctx context.Context, backend transaction.Backend, t *big.Int) error {
+	for {
+		now, err := chainTime(ctx, backend)
+		if err != nil {
+			return err
+		}
+		if now.Cmp(t) >= 0 {
+			return nil
+		}
+
+		wait := chainTimePollInterval
+		if remaining := time.Duration(new(big.Int).Sub(t, now).Int64()) * time.Second; remaining < wait {
+			wait = remaining
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
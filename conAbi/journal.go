@@ -0,0 +1,185 @@
+package conAbi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"backend-demo/transaction"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ResumeJournal picks up the transactions of TxJournal which were not
+// finished when the process stopped. Those already mined or replaced by
+// someone else get their final status, those the node does not know
+// (anymore) are broadcast again. Pending transactions of the account of
+// signer are handed to the Monitor, and all of them are waited for in the
+// background until ctx is done. Entries which are final for longer than
+// JournalRetention are removed.
+func ResumeJournal(ctx context.Context, backend transaction.Backend, signer transaction.Signer) error {
+	pruned, err := TxJournal.Prune(time.Now().Add(-JournalRetention))
+	if err != nil {
+		return err
+	}
+	if DebugFlag && pruned > 0 {
+		fmt.Println("ResumeJournal: pruned ", pruned, " finished transactions")
+	}
+
+	entries, err := TxJournal.Unfinished()
+	if err != nil {
+		return err
+	}
+
+	var account common.Address
+	if signer != nil {
+		account, err = signer.EthereumAddress()
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, entry := range entries {
+		pending, err := resumeEntry(ctx, backend, entry)
+		if err != nil {
+			return fmt.Errorf("resume transaction %x: %w", entry.Hash, err)
+		}
+		if !pending {
+			continue
+		}
+
+		if DebugFlag {
+			fmt.Println("ResumeJournal: waiting for ", entry.Request.Description, " tx ", entry.LatestHash())
+		}
+
+		if Monitor != nil && signer != nil && entry.Sender == account {
+			tx, err := entry.Transaction()
+			if err != nil {
+				return err
+			}
+			hashes := entry.Hashes()
+			Monitor.Track(tx, entry.Sender, signer, hashes[:len(hashes)-1]...)
+		}
+
+		go func(txHash common.Hash) {
+			_, err := WaitMined(ctx, txHash, backend)
+			if err != nil && DebugFlag {
+				fmt.Println("ResumeJournal: wait for ", txHash, " failed: ", err)
+			}
+		}(entry.LatestHash())
+	}
+
+	return nil
+}
+
+// resumeEntry brings a single journal entry up to date with the chain and
+// returns true if the transaction is pending.
+func resumeEntry(ctx context.Context, backend transaction.Backend, entry *transaction.JournalEntry) (bool, error) {
+	for _, hash := range entry.Hashes() {
+		receipt, err := backend.TransactionReceipt(ctx, hash)
+		if err != nil {
+			if errors.Is(err, ethereum.NotFound) {
+				continue
+			}
+			return false, err
+		}
+		if receipt != nil {
+			journalReceipt(receipt)
+			return false, nil
+		}
+	}
+
+	confirmed, err := backend.NonceAt(ctx, entry.Sender, nil)
+	if err != nil {
+		return false, err
+	}
+	if confirmed > entry.Nonce {
+		journalStatus(entry.Hash, transaction.TxStatusDropped, common.Hash{}, transaction.ErrNonceUsed)
+		return false, nil
+	}
+
+	tx, err := entry.Transaction()
+	if err != nil {
+		return false, err
+	}
+
+	_, _, err = backend.TransactionByHash(ctx, tx.Hash())
+	if err == nil {
+		journalStatus(entry.Hash, transaction.TxStatusPending, common.Hash{}, nil)
+		return true, nil
+	}
+	if !errors.Is(err, ethereum.NotFound) {
+		return false, err
+	}
+
+	// never broadcast or dropped by the node, the signed transaction is still valid
+	err = backend.SendTransaction(ctx, tx)
+	if err != nil {
+		journalStatus(entry.Hash, transaction.TxStatusFailed, common.Hash{}, err)
+		return false, nil
+	}
+
+	err = nonceManager(backend, entry.Sender).Commit(tx.Nonce(), tx.Hash())
+	if err != nil && DebugFlag {
+		fmt.Println("ResumeJournal: commit nonce failed: ", err)
+	}
+	journalStatus(entry.Hash, transaction.TxStatusPending, common.Hash{}, nil)
+
+	return true, nil
+}
+
+// journalStatus updates the journal entry of txHash. Transactions which were
+// not sent by Send are not journaled, other failures are only logged.
+func journalStatus(txHash common.Hash, status transaction.TxStatus, minedHash common.Hash, cause error) {
+	err := TxJournal.SetStatus(txHash, status, minedHash, cause)
+	if err != nil && !errors.Is(err, transaction.ErrJournalEntryNotFound) && DebugFlag {
+		fmt.Println("journalStatus: update ", txHash, " failed: ", err)
+	}
+}
+
+// journalReceipt records the outcome of a mined transaction in the journal.
+func journalReceipt(receipt *types.Receipt) {
+	status := transaction.TxStatusMined
+	if isCancel(receipt.TxHash) {
+		status = transaction.TxStatusCancelled
+	} else if receipt.Status != types.ReceiptStatusSuccessful {
+		status = transaction.TxStatusReverted
+	}
+	journalStatus(receipt.TxHash, status, receipt.TxHash, nil)
+}
+
+// journalCancel records in the journal that tx is cancelled by cancelTx. A
+// transaction which was not sent by Send is journaled first, so that waiting
+// for it learns about the cancellation.
+func journalCancel(sender common.Address, tx, cancelTx *types.Transaction) error {
+	_, err := TxJournal.Get(tx.Hash())
+	if errors.Is(err, transaction.ErrJournalEntryNotFound) {
+		err = TxJournal.Add(sender, requestOf(tx, ""), tx)
+		if err == nil {
+			err = TxJournal.SetStatus(tx.Hash(), transaction.TxStatusPending, common.Hash{}, nil)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return TxJournal.Cancel(tx.Hash(), cancelTx)
+}
+
+// isCancel returns true if txHash is a journaled cancellation, see Cancel.
+func isCancel(txHash common.Hash) bool {
+	entry, err := TxJournal.Get(txHash)
+	return err == nil && entry.IsCancel(txHash)
+}
+
+// journalReplace records in the journal that txHash was replaced. Transactions
+// which were not sent by Send are not journaled, that is not an error.
+func journalReplace(txHash common.Hash, replacement *types.Transaction) error {
+	err := TxJournal.Replace(txHash, replacement)
+	if err != nil && !errors.Is(err, transaction.ErrJournalEntryNotFound) {
+		return err
+	}
+	return nil
+}
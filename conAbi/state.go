@@ -0,0 +1,162 @@
+package conAbi
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"backend-demo/transaction"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ChequebookState is a consistent view of a chequebook, all values read at BlockNumber.
+type ChequebookState struct {
+	BlockNumber               *big.Int
+	Balance                   *big.Int
+	LiquidBalance             *big.Int
+	TotalHardDeposit          *big.Int
+	TotalPaidOut              *big.Int
+	Issuer                    common.Address
+	Token                     common.Address
+	Bounced                   bool
+	WithdrawTime              *big.Int
+	DefaultHardDepositTimeout *big.Int
+}
+
+// GetChequebookState reads the full state of the chequebook. All values are
+// read at the same block so that they are consistent with each other.
+func GetChequebookState(ctx context.Context, backend transaction.Backend, swapAdd common.Address) (*ChequebookState, error) {
+	number, err := backend.BlockNumber(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &ChequebookState{
+		BlockNumber: new(big.Int).SetUint64(number),
+	}
+
+	fields := []struct {
+		method string
+		out    interface{}
+	}{
+		{"balance", &state.Balance},
+		{"liquidBalance", &state.LiquidBalance},
+		{"totalHardDeposit", &state.TotalHardDeposit},
+		{"totalPaidOut", &state.TotalPaidOut},
+		{"issuer", &state.Issuer},
+		{"token", &state.Token},
+		{"bounced", &state.Bounced},
+		{"withdrawTime", &state.WithdrawTime},
+		{"defaultHardDepositTimeout", &state.DefaultHardDepositTimeout},
+	}
+
+	for _, field := range fields {
+		err = callSwapAt(ctx, backend, swapAdd, state.BlockNumber, field.out, field.method)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", field.method, err)
+		}
+	}
+
+	return state, nil
+}
+
+// callSwapAt calls a single-result view method of the chequebook at the given
+// block and stores the result in out.
+func callSwapAt(ctx context.Context, backend transaction.Backend, swapAdd common.Address, blockNumber *big.Int, out interface{}, method string, args ...interface{}) error {
+	callData, err := swapABI.Pack(method, args...)
+	if err != nil {
+		return err
+	}
+
+	output, err := CallAt(ctx, tmpAdd, &transaction.TxRequest{
+		To:   &swapAdd,
+		Data: callData,
+	}, backend, blockNumber)
+	if err != nil {
+		return err
+	}
+
+	return swapABI.UnpackIntoInterface(out, method, output)
+}
+
+// BeneficiaryState is the view of a chequebook from the perspective of a
+// single beneficiary, all values read at BlockNumber.
+type BeneficiaryState struct {
+	BlockNumber   *big.Int
+	Beneficiary   common.Address
+	LiquidBalance *big.Int // balance available to this beneficiary, including its hard deposit
+	PaidOut       *big.Int // amount already cashed out by this beneficiary
+	HardDeposit   HardDeposit
+}
+
+// GetLiquidBalanceFor returns the part of the chequebook balance available to beneficiary.
+func GetLiquidBalanceFor(ctx context.Context, backend transaction.Backend, swapAdd common.Address, beneficiary common.Address) (*big.Int, error) {
+	var liquidBalance *big.Int
+	err := callSwapAt(ctx, backend, swapAdd, nil, &liquidBalance, "liquidBalanceFor", beneficiary)
+	if err != nil {
+		return nil, err
+	}
+	return liquidBalance, nil
+}
+
+// GetPaidOut returns the amount beneficiary has already cashed out from the chequebook.
+func GetPaidOut(ctx context.Context, backend transaction.Backend, swapAdd common.Address, beneficiary common.Address) (*big.Int, error) {
+	var paidOut *big.Int
+	err := callSwapAt(ctx, backend, swapAdd, nil, &paidOut, "paidOut", beneficiary)
+	if err != nil {
+		return nil, err
+	}
+	return paidOut, nil
+}
+
+// GetBeneficiaryState reads liquidBalanceFor, paidOut and hardDeposits of
+// beneficiary at the same block.
+func GetBeneficiaryState(ctx context.Context, backend transaction.Backend, swapAdd common.Address, beneficiary common.Address) (*BeneficiaryState, error) {
+	number, err := backend.BlockNumber(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &BeneficiaryState{
+		BlockNumber: new(big.Int).SetUint64(number),
+		Beneficiary: beneficiary,
+	}
+
+	fields := []struct {
+		method string
+		out    interface{}
+	}{
+		{"liquidBalanceFor", &state.LiquidBalance},
+		{"paidOut", &state.PaidOut},
+		{"hardDeposits", &state.HardDeposit},
+	}
+
+	for _, field := range fields {
+		err = callSwapAt(ctx, backend, swapAdd, state.BlockNumber, field.out, field.method, beneficiary)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", field.method, err)
+		}
+	}
+
+	return state, nil
+}
+
+// Uncashed returns the part of cumulativePayout which has not been cashed yet.
+func (s *BeneficiaryState) Uncashed(cumulativePayout *big.Int) *big.Int {
+	uncashed := new(big.Int).Sub(cumulativePayout, s.PaidOut)
+	if uncashed.Sign() < 0 {
+		return big.NewInt(0)
+	}
+	return uncashed
+}
+
+// Covered returns how much of the uncashed value of cumulativePayout the
+// chequebook could currently pay out to the beneficiary.
+func (s *BeneficiaryState) Covered(cumulativePayout *big.Int) *big.Int {
+	uncashed := s.Uncashed(cumulativePayout)
+	if uncashed.Cmp(s.LiquidBalance) > 0 {
+		return new(big.Int).Set(s.LiquidBalance)
+	}
+	return uncashed
+}
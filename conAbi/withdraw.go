@@ -0,0 +1,229 @@
+package conAbi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"backend-demo/storage"
+	"backend-demo/transaction"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const withdrawStatePrefix = "withdraw_"
+
+var (
+	withdrawEventType = swapABI.Events["Withdraw"]
+)
+
+type withdrawEvent struct {
+	Amount *big.Int
+}
+
+// withdrawState is persisted after every step of the withdraw workflow so it
+// can resume where it was interrupted. The Sending times are recorded before
+// a transaction is sent, so that a run interrupted before it could record the
+// hash finds the transaction in the TxJournal instead of sending another one.
+type withdrawState struct {
+	Amount             *big.Int
+	PreWithdrawSending time.Time
+	PreWithdrawTx      common.Hash
+	WithdrawSending    time.Time
+	WithdrawTx         common.Hash
+}
+
+func withdrawKey(swapAdd common.Address) string {
+	return fmt.Sprintf("%s%x", withdrawStatePrefix, swapAdd)
+}
+
+// Withdraw withdraws amount from the chequebook to the issuer. It only
+// succeeds after preWithdraw was called and withdrawTime has passed.
+func Withdraw(ctx context.Context, fromaddress common.Address, backend transaction.Backend, swapAdd common.Address, amount *big.Int, signer transaction.Signer, chainID *big.Int) (common.Hash, error) {
+	callData, err := swapABI.Pack("withdraw", amount)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	request := &transaction.TxRequest{
+		To:          &swapAdd,
+		Data:        callData,
+		Value:       big.NewInt(0),
+		Description: "withdraw",
+	}
+
+	return Send(ctx, request, backend, fromaddress, signer, chainID)
+}
+
+// WithdrawWorkflow runs the complete withdrawal of amount from the chequebook:
+// it sends preWithdraw, waits until the chain time passes withdrawTime, sends
+// withdraw and confirms the Withdraw event. The progress is kept in store so
+// that calling it again after an interruption continues the pending
+// withdrawal instead of starting a new one. A transaction sent just before an
+// interruption is looked up in the TxJournal, so the journal has to be
+// persisted as well, see UseStateStore. It returns the withdrawn amount.
+func WithdrawWorkflow(ctx context.Context, fromaddress common.Address, backend transaction.Backend, store storage.StateStorer, swapAdd common.Address, amount *big.Int, signer transaction.Signer, chainID *big.Int) (*big.Int, error) {
+	key := withdrawKey(swapAdd)
+
+	var state withdrawState
+	err := store.Get(key, &state)
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			return nil, err
+		}
+		state.Amount = amount
+	} else if state.Amount.Cmp(amount) != 0 {
+		return nil, fmt.Errorf("pending withdrawal of %v in progress", state.Amount)
+	}
+
+	if state.WithdrawTx == (common.Hash{}) {
+		if state.PreWithdrawTx == (common.Hash{}) {
+			callData, err := swapABI.Pack("preWithdraw")
+			if err != nil {
+				return nil, err
+			}
+			txHash, found, err := journaledTx(fromaddress, swapAdd, callData, state.PreWithdrawSending)
+			if err != nil {
+				return nil, err
+			}
+			if !found {
+				state.PreWithdrawSending = time.Now().UTC()
+				if err := store.Put(key, &state); err != nil {
+					return nil, err
+				}
+				txHash, err = PreWithdraw(ctx, fromaddress, backend, swapAdd, signer, chainID)
+				if err != nil {
+					return nil, err
+				}
+			}
+			state.PreWithdrawTx = txHash
+			if err := store.Put(key, &state); err != nil {
+				return nil, err
+			}
+		}
+
+		if DebugFlag {
+			fmt.Println("WithdrawWorkflow: preWithdraw tx hash is ", state.PreWithdrawTx)
+		}
+
+		receipt, err := WaitMined(ctx, state.PreWithdrawTx, backend)
+		if errors.Is(err, ErrCancelled) {
+			// forget the cancelled preWithdraw so the next run starts over
+			if err := store.Delete(key); err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("pre withdraw failed: %w", err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if receipt.Status != 1 {
+			// forget the failed preWithdraw so the next run starts over
+			if err := store.Delete(key); err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("pre withdraw failed: %w", ErrTransactionReverted)
+		}
+
+		withdrawTime, err := GetWithdrawTime(ctx, backend, swapAdd)
+		if err != nil {
+			return nil, err
+		}
+
+		if DebugFlag {
+			fmt.Println("WithdrawWorkflow: waiting until ", time.Unix(withdrawTime.Int64(), 0))
+		}
+
+		err = waitChainTime(ctx, backend, withdrawTime)
+		if err != nil {
+			return nil, err
+		}
+
+		callData, err := swapABI.Pack("withdraw", state.Amount)
+		if err != nil {
+			return nil, err
+		}
+		txHash, found, err := journaledTx(fromaddress, swapAdd, callData, state.WithdrawSending)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			state.WithdrawSending = time.Now().UTC()
+			if err := store.Put(key, &state); err != nil {
+				return nil, err
+			}
+			txHash, err = Withdraw(ctx, fromaddress, backend, swapAdd, state.Amount, signer, chainID)
+			if err != nil {
+				return nil, err
+			}
+		}
+		state.WithdrawTx = txHash
+		if err := store.Put(key, &state); err != nil {
+			return nil, err
+		}
+	}
+
+	if DebugFlag {
+		fmt.Println("WithdrawWorkflow: withdraw tx hash is ", state.WithdrawTx)
+	}
+
+	receipt, err := WaitMined(ctx, state.WithdrawTx, backend)
+	if err != nil && !errors.Is(err, ErrCancelled) {
+		return nil, err
+	}
+
+	var event withdrawEvent
+	if err == nil {
+		err = FindSingleEvent(&swapABI, receipt, swapAdd, withdrawEventType, &event)
+	}
+	if errors.Is(err, ErrTransactionReverted) || errors.Is(err, ErrCancelled) {
+		// withdrawTime is still set, retry only the withdraw on the next run
+		state.WithdrawSending = time.Time{}
+		state.WithdrawTx = common.Hash{}
+		if err := store.Put(key, &state); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("withdraw failed: %w", err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("withdraw failed: %w", err)
+	}
+
+	if err := store.Delete(key); err != nil {
+		return nil, err
+	}
+
+	return event.Amount, nil
+}
+
+// journaledTx returns the latest transaction with data from fromaddress to
+// swapAdd which was journaled since the given time and did not fail. It finds
+// nothing if since is zero.
+func journaledTx(fromaddress common.Address, swapAdd common.Address, data []byte, since time.Time) (common.Hash, bool, error) {
+	if since.IsZero() {
+		return common.Hash{}, false, nil
+	}
+
+	entries, err := TxJournal.Entries()
+	if err != nil {
+		return common.Hash{}, false, err
+	}
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if entry.Created.Before(since) {
+			break
+		}
+		if entry.Sender != fromaddress || entry.Request.To == nil || *entry.Request.To != swapAdd || !bytes.Equal(entry.Request.Data, data) {
+			continue
+		}
+		if entry.Status == transaction.TxStatusFailed || entry.Status == transaction.TxStatusDropped {
+			continue
+		}
+		return entry.Hash, true, nil
+	}
+	return common.Hash{}, false, nil
+}
@@ -3,7 +3,8 @@ module backend-demo
 go 1.16
 
 require (
-	github.com/btcsuite/btcd v0.20.1-beta // indirect
-	github.com/ethereum/go-ethereum v1.10.8 // indirect
-	golang.org/x/crypto v0.0.0-20210322153248-0c34fe9e7dc2 // indirect
+	github.com/btcsuite/btcd v0.20.1-beta
+	github.com/ethereum/go-ethereum v1.10.8
+	github.com/tyler-smith/go-bip39 v1.0.1-0.20181017060643-dbb3b84ba2ef
+	golang.org/x/crypto v0.0.0-20210322153248-0c34fe9e7dc2
 )
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"backend-demo/transaction"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	cr "github.com/ethereum/go-ethereum/crypto"
+)
+
+// openKeystore opens the keystore directory dir, it is created on first use.
+func openKeystore(dir string) *keystore.KeyStore {
+	return keystore.NewKeyStore(dir, keystore.StandardScryptN, keystore.StandardScryptP)
+}
+
+// passphraseSource reads the passphrase from file if given, otherwise from
+// the passphraseEnv environment variable or the terminal.
+func passphraseSource(file string, prompt string, confirm bool) transaction.PassphraseSource {
+	return transaction.PassphraseSource{
+		File:    file,
+		Env:     passphraseEnv,
+		Prompt:  prompt,
+		Confirm: confirm,
+	}
+}
+
+// loadSigner unlocks the key of account in the keystore directory dir.
+func loadSigner(dir string, account common.Address, passwordFile string) (transaction.Signer, error) {
+	found, err := openKeystore(dir).Find(accounts.Account{Address: account})
+	if err != nil {
+		return nil, fmt.Errorf("key of %s: %w", account.Hex(), err)
+	}
+
+	return transaction.NewKeystoreSigner(found.URL.Path, passphraseSource(passwordFile, fmt.Sprintf("Passphrase for %s: ", account.Hex()), false))
+}
+
+// signerFlags select the key to sign with: a keystore file, an account
+// derived from a mnemonic or a remote signer.
+type signerFlags struct {
+	dir          *string
+	passwordFile *string
+	hd           *bool
+	mnemonicFile *string
+	hdAccounts   *int
+	remote       *string
+}
+
+func addSignerFlags(flags *flag.FlagSet) *signerFlags {
+	return &signerFlags{
+		dir:          flags.String("keystore", keystoreDir, "keystore directory"),
+		passwordFile: flags.String("password-file", "", "file with the passphrase of the key or the bip39 passphrase"),
+		hd:           flags.Bool("hd", false, "sign with an account derived from the mnemonic instead of the keystore"),
+		mnemonicFile: flags.String("mnemonic-file", "", "file with the mnemonic, implies -hd"),
+		hdAccounts:   flags.Int("hd-accounts", 100, "number of derived accounts to search for the account"),
+		remote:       flags.String("remote", "", "endpoint of a clef compatible remote signer to use instead of a local key"),
+	}
+}
+
+// signer returns the signer for account.
+func (f *signerFlags) signer(account common.Address) (transaction.Signer, error) {
+	if *f.remote != "" {
+		return transaction.DialClefSigner(*f.remote, account)
+	}
+
+	if *f.hd || *f.mnemonicFile != "" {
+		wallet, err := openHDWallet(*f.mnemonicFile, *f.passwordFile)
+		if err != nil {
+			return nil, err
+		}
+		return wallet.FindSigner(account, *f.hdAccounts)
+	}
+
+	return loadSigner(*f.dir, account, *f.passwordFile)
+}
+
+// keyNew creates a new key in the keystore.
+func keyNew(args []string) error {
+	flags := flag.NewFlagSet("key-new", flag.ExitOnError)
+	dir := flags.String("keystore", keystoreDir, "keystore directory")
+	passwordFile := flags.String("password-file", "", "file with the passphrase for the new key")
+	flags.Parse(args)
+
+	passphrase, err := passphraseSource(*passwordFile, "Passphrase for the new key: ", true).Passphrase()
+	if err != nil {
+		return err
+	}
+
+	account, err := openKeystore(*dir).NewAccount(passphrase)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(account.Address.Hex(), account.URL.Path)
+	return nil
+}
+
+// keyImport encrypts a hex encoded private key into the keystore.
+func keyImport(args []string) error {
+	flags := flag.NewFlagSet("key-import", flag.ExitOnError)
+	dir := flags.String("keystore", keystoreDir, "keystore directory")
+	keyFile := flags.String("key", "", "file with the hex encoded private key")
+	passwordFile := flags.String("password-file", "", "file with the passphrase for the imported key")
+	flags.Parse(args)
+
+	pk, err := cr.LoadECDSA(*keyFile)
+	if err != nil {
+		return err
+	}
+
+	passphrase, err := passphraseSource(*passwordFile, "Passphrase for the imported key: ", true).Passphrase()
+	if err != nil {
+		return err
+	}
+
+	account, err := openKeystore(*dir).ImportECDSA(pk, passphrase)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(account.Address.Hex(), account.URL.Path)
+	return nil
+}
+
+// keyList prints the accounts in the keystore.
+func keyList(args []string) error {
+	flags := flag.NewFlagSet("key-list", flag.ExitOnError)
+	dir := flags.String("keystore", keystoreDir, "keystore directory")
+	flags.Parse(args)
+
+	for _, account := range openKeystore(*dir).Accounts() {
+		fmt.Println(account.Address.Hex(), account.URL.Path)
+	}
+	return nil
+}
+
+// keyExport writes the key of an account as keystore file encrypted with a
+// new passphrase, e.g. to move it to another machine.
+func keyExport(args []string) error {
+	flags := flag.NewFlagSet("key-export", flag.ExitOnError)
+	dir := flags.String("keystore", keystoreDir, "keystore directory")
+	exportAddress := flags.String("address", "", "account to export")
+	passwordFile := flags.String("password-file", "", "file with the passphrase of the key")
+	newPasswordFile := flags.String("new-password-file", "", "file with the passphrase for the exported key")
+	out := flags.String("out", "", "file to write the exported key to, stdout if empty")
+	flags.Parse(args)
+
+	if !common.IsHexAddress(*exportAddress) {
+		return fmt.Errorf("invalid address %q", *exportAddress)
+	}
+
+	ks := openKeystore(*dir)
+	account, err := ks.Find(accounts.Account{Address: common.HexToAddress(*exportAddress)})
+	if err != nil {
+		return err
+	}
+
+	passphrase, err := passphraseSource(*passwordFile, "Passphrase of the key: ", false).Passphrase()
+	if err != nil {
+		return err
+	}
+	// the environment variable is for unlocking only, the new passphrase is asked for explicitly
+	newPassphrase, err := transaction.PassphraseSource{
+		File:    *newPasswordFile,
+		Prompt:  "Passphrase for the exported key: ",
+		Confirm: true,
+	}.Passphrase()
+	if err != nil {
+		return err
+	}
+	if newPassphrase == "" {
+		return errors.New("refusing to export without passphrase")
+	}
+
+	keyJSON, err := ks.Export(account, passphrase, newPassphrase)
+	if err != nil {
+		return err
+	}
+
+	return writeOutput(*out, keyJSON)
+}
+
+// openHDWallet reads the mnemonic from file, the mnemonicEnv environment
+// variable or the terminal and the optional bip39 passphrase from
+// passwordFile.
+func openHDWallet(mnemonicFile string, passwordFile string) (*transaction.HDWallet, error) {
+	mnemonic, err := transaction.PassphraseSource{
+		File:   mnemonicFile,
+		Env:    mnemonicEnv,
+		Prompt: "Mnemonic: ",
+	}.Passphrase()
+	if err != nil {
+		return nil, err
+	}
+
+	var passphrase string
+	if passwordFile != "" {
+		passphrase, err = transaction.PassphraseSource{File: passwordFile}.Passphrase()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return transaction.NewHDWallet(strings.Join(strings.Fields(mnemonic), " "), passphrase)
+}
+
+// hdNew generates a new mnemonic, it is the backup of all derived accounts.
+func hdNew(args []string) error {
+	flags := flag.NewFlagSet("hd-new", flag.ExitOnError)
+	out := flags.String("out", "", "file to write the mnemonic to, stdout if empty")
+	flags.Parse(args)
+
+	mnemonic, err := transaction.NewMnemonic()
+	if err != nil {
+		return err
+	}
+	return writeOutput(*out, []byte(mnemonic+"\n"))
+}
+
+// hdList prints the first accounts derived from a mnemonic.
+func hdList(args []string) error {
+	flags := flag.NewFlagSet("hd-list", flag.ExitOnError)
+	mnemonicFile := flags.String("mnemonic-file", "", "file with the mnemonic")
+	passwordFile := flags.String("password-file", "", "file with the optional bip39 passphrase")
+	count := flags.Int("n", 10, "number of accounts")
+	flags.Parse(args)
+
+	wallet, err := openHDWallet(*mnemonicFile, *passwordFile)
+	if err != nil {
+		return err
+	}
+
+	addresses, err := wallet.Addresses(*count)
+	if err != nil {
+		return err
+	}
+	for i, address := range addresses {
+		fmt.Println(transaction.AccountPath(uint32(i)), address.Hex())
+	}
+	return nil
+}
@@ -2,24 +2,51 @@ package main
 
 import (
 	"context"
-	"crypto/ecdsa"
+	"errors"
 	"fmt"
 	"log"
+	"os"
+	"time"
 
 	"backend-demo/conAbi"
+	"backend-demo/storage"
+	"backend-demo/transaction"
 
 	"github.com/ethereum/go-ethereum/common"
-	cr "github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
 )
 
 const (
-	endPoint   = "http://18.144.29.246:8110"
-	privateKey = "e484c4373db5c55a9813e4abbb74a15edd794019b8db4365a876ed538622bcf9"
-	address    = "0xA4E7663A031ca1f67eEa828E4795653504d38c6e"
+	endPoint    = "http://18.144.29.246:8110"
+	address     = "0xA4E7663A031ca1f67eEa828E4795653504d38c6e" // issuer of the demo chequebook, its key has to be in keystoreDir
+	stateFile   = "state.json"
+	keystoreDir = "keystore"
+
+	passphraseEnv = "KEYSTORE_PASSPHRASE" // passphrase of the keystore key, prompted for if not set
+	mnemonicEnv   = "HD_MNEMONIC"         // mnemonic of the hd wallet, prompted for if not set
+	signerEnv     = "SIGNER_ENDPOINT"     // clef compatible remote signer to use instead of the keystore
+
+	replaceAfter = 3 * time.Minute // replace transactions pending longer than this
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		err := runCommand(os.Args[1], os.Args[2:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	store, err := storage.NewFileStore(stateFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer store.Close()
+
+	conAbi.UseStateStore(store)
+	chequeStore := conAbi.NewChequeStore(store)
+
 	client, err := ethclient.Dial(endPoint)
 	if err != nil {
 		log.Fatal(err)
@@ -31,18 +58,42 @@ func main() {
 		log.Fatal(err)
 	}
 
-	pk, err := cr.HexToECDSA(privateKey)
+	monitor := conAbi.StartTxMonitor(client, replaceAfter)
+	defer monitor.Close()
+
+	// the key is unlocked with the passphrase from the environment or the
+	// terminal, unless a remote signer is configured
+	var signer transaction.Signer
+	if endpoint, ok := os.LookupEnv(signerEnv); ok {
+		signer, err = transaction.DialClefSigner(endpoint, common.HexToAddress(address))
+	} else {
+		signer, err = loadSigner(keystoreDir, common.HexToAddress(address), "")
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fromAddress, err := signer.EthereumAddress()
 	if err != nil {
 		log.Fatal(err)
 	}
+	conAbi.SenderAdd = fromAddress
 
-	publicKey := pk.Public()
-	publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
-	if !ok {
-		log.Fatal("error casting public key to ECDSA")
+	// pick up transactions which were still pending when we stopped last time
+	err = conAbi.ResumeJournal(context.Background(), client, signer)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	fromAddress := cr.PubkeyToAddress(*publicKeyECDSA)
+	if conAbi.DebugFlag {
+		entries, err := conAbi.TxJournal.Entries()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, entry := range entries {
+			fmt.Println("journal: ", entry.Request.Description, " nonce ", entry.Nonce, " tx ", entry.LatestHash(), " is ", entry.Status)
+		}
+	}
 
 	nonce, err := client.PendingNonceAt(context.Background(), fromAddress)
 	if err != nil {
@@ -83,21 +134,17 @@ func main() {
 
 	swapAdd := common.HexToAddress("0xC721594D255Aa52B442a67603593673646835759")
 
-	//singer := transaction.NewDefaultSigner(pk)
+	lastCheque, err := chequeStore.LastSentCheque(swapAdd, fromAddress)
+	if err != nil && !errors.Is(err, conAbi.ErrNoCheque) {
+		log.Fatal(err)
+	}
+	if conAbi.DebugFlag && lastCheque != nil {
+		fmt.Println("last sent cheque cumulativePayout is ", lastCheque.CumulativePayout)
+	}
 
 	//已经部署一套swap合约 0xC721594D255Aa52B442a67603593673646835759
 	/*
-		txhash, err := conAbi.Deploy(context.Background(), conAbi.SenderAdd, big.NewInt(100), common.BigToHash(big.NewInt(100)), client, singer, chainID)
-
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		fmt.Println("txhash is ", txhash)
-
-		time.Sleep(time.Duration(20) * time.Second)
-
-		swapAdd, err := conAbi.WaitDeployed(context.Background(), txhash, client)
+		swapAdd, err := conAbi.EnsureDeployed(context.Background(), conAbi.SenderAdd, big.NewInt(100), common.BigToHash(big.NewInt(100)), client, signer, chainID)
 
 		if err != nil {
 			log.Fatal(err)
@@ -136,43 +183,28 @@ func main() {
 	} else {
 		log.Println("verify chequebook ok")
 	}
-	/*
-
-		if conAbi.DebugFlag {
-			fmt.Println("run preWithdraw")
-		}
-
-		withdrawTime1, err := conAbi.GetWithdrawTime(context.Background(), client, swapAdd)
-
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		if conAbi.DebugFlag {
-			fmt.Println("withdrawTime1 is ", withdrawTime1)
-		}
 
-		txHash, err := conAbi.PreWithdraw(context.Background(), fromAddress, client, swapAdd, singer, chainID)
-
-		if err != nil {
-			log.Fatal(err)
-		}
+	chequebookState, err := conAbi.GetChequebookState(context.Background(), client, swapAdd)
+	if err != nil {
+		log.Fatal(err)
+	}
 
+	if conAbi.DebugFlag {
+		fmt.Printf("chequebook state at block %v is %+v\n", chequebookState.BlockNumber, chequebookState)
+	}
+	/*
 		if conAbi.DebugFlag {
-			fmt.Println("PreWithdraw tx hash is  ", txHash)
+			fmt.Println("run withdraw")
 		}
 
-		time.Sleep(time.Duration(20) * time.Second)
-
-		withdrawTime2, err := conAbi.GetWithdrawTime(context.Background(), client, swapAdd)
+		withdrawn, err := conAbi.WithdrawWorkflow(context.Background(), fromAddress, client, store, swapAdd, big.NewInt(100), signer, chainID)
 
 		if err != nil {
 			log.Fatal(err)
 		}
 
 		if conAbi.DebugFlag {
-			fmt.Println("withdrawTime2 is ", withdrawTime2)
-			fmt.Println("withdrawTime2 is ", time.Unix(withdrawTime2.Int64(), 0))
+			fmt.Println("withdrawn is ", withdrawn)
 		}
 	*/
 
@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// fileStore is a StateStorer that keeps all entries in memory and writes the
+// whole set to a single json file on every change.
+type fileStore struct {
+	mu   sync.Mutex
+	path string
+	data map[string]json.RawMessage
+}
+
+// NewFileStore opens the store persisted at path, creating it if it does not exist.
+func NewFileStore(path string) (StateStorer, error) {
+	s := &fileStore{
+		path: path,
+		data: make(map[string]json.RawMessage),
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return s, nil
+		}
+		return nil, err
+	}
+
+	if len(content) > 0 {
+		if err := json.Unmarshal(content, &s.data); err != nil {
+			return nil, fmt.Errorf("storage: corrupted state file %s: %w", path, err)
+		}
+	}
+
+	return s, nil
+}
+
+// NewMemStore returns a StateStorer which is not persisted.
+func NewMemStore() StateStorer {
+	return &fileStore{
+		data: make(map[string]json.RawMessage),
+	}
+}
+
+func (s *fileStore) Get(key string, i interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	value, ok := s.data[key]
+	if !ok {
+		return ErrNotFound
+	}
+	return json.Unmarshal(value, i)
+}
+
+func (s *fileStore) Put(key string, i interface{}) error {
+	value, err := json.Marshal(i)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, existed := s.data[key]
+	s.data[key] = value
+	if err := s.flush(); err != nil {
+		if existed {
+			s.data[key] = old
+		} else {
+			delete(s.data, key)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *fileStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, existed := s.data[key]
+	if !existed {
+		return nil
+	}
+	delete(s.data, key)
+	if err := s.flush(); err != nil {
+		s.data[key] = old
+		return err
+	}
+	return nil
+}
+
+func (s *fileStore) Iterate(prefix string, iterFunc IterateFunc) error {
+	s.mu.Lock()
+	keys := make([]string, 0, len(s.data))
+	for key := range s.data {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		values[i] = s.data[key]
+	}
+	s.mu.Unlock()
+
+	for i, key := range keys {
+		stop, err := iterFunc([]byte(key), values[i])
+		if err != nil {
+			return err
+		}
+		if stop {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (s *fileStore) Close() error {
+	return nil
+}
+
+// flush writes the data to a temporary file and renames it over the state
+// file so that a crash never leaves a partially written file behind.
+func (s *fileStore) flush() error {
+	if s.path == "" {
+		return nil
+	}
+
+	content, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,29 @@
+package storage
+
+import (
+	"errors"
+)
+
+var (
+	// ErrNotFound is returned when a key does not exist in the store.
+	ErrNotFound = errors.New("storage: not found")
+)
+
+// IterateFunc is called for every entry in the store by Iterate. Returning
+// stop as true ends the iteration.
+type IterateFunc func(key, value []byte) (stop bool, err error)
+
+// StateStorer is the key value store used to persist state between runs.
+// Values are json encoded.
+type StateStorer interface {
+	// Get unmarshals the value stored under key into i.
+	Get(key string, i interface{}) error
+	// Put marshals i and stores it under key.
+	Put(key string, i interface{}) error
+	// Delete removes the key from the store.
+	Delete(key string) error
+	// Iterate calls iterFunc for every key with the given prefix.
+	Iterate(prefix string, iterFunc IterateFunc) error
+	// Close releases the resources of the store.
+	Close() error
+}
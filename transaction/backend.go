@@ -16,15 +16,26 @@ import (
 )
 
 // TxRequest describes a request for a transaction that can be executed.
+// If GasPrice is set a legacy transaction is created, otherwise a dynamic fee
+// (eip1559) transaction if the chain supports it.
 type TxRequest struct {
-	To          *common.Address // recipient of the transaction
-	Data        []byte          // transaction data
-	GasPrice    *big.Int        // gas price or nil if suggested gas price should be used
-	GasLimit    uint64          // gas limit or 0 if it should be estimated
-	Value       *big.Int        // amount of wei to send
-	Description string          // optional description
+	To                   *common.Address // recipient of the transaction
+	Data                 []byte          // transaction data
+	GasPrice             *big.Int        // gas price or nil if suggested gas price should be used
+	MaxFeePerGas         *big.Int        // eip1559 fee cap or nil if it should be derived from the base fee
+	MaxPriorityFeePerGas *big.Int        // eip1559 tip or nil if suggested tip should be used
+	GasLimit             uint64          // gas limit or 0 if it should be estimated
+	Value                *big.Int        // amount of wei to send
+	Description          string          // optional description
 }
 
+var (
+	// ErrWaitTimeout is returned when a transaction was not mined within the timeout.
+	ErrWaitTimeout = errors.New("timeout waiting for transaction")
+	// ErrLondonNotActive is returned when eip1559 fees are requested on a chain without base fee.
+	ErrLondonNotActive = errors.New("eip1559 is not active on this chain")
+)
+
 type SimpleSwapDeployedEvent struct {
 	ContractAddress common.Address
 }
@@ -104,6 +115,72 @@ func WaitBlock(ctx context.Context, backend Backend, pollingInterval time.Durati
 	}
 }
 
+// WaitMined waits until the transaction with txHash is mined and has the
+// given number of confirmations, i.e. confirmations-1 blocks were built on top
+// of the block containing it. The receipt is polled every pollingInterval. A
+// timeout of 0 waits until ctx is done. If the transaction is moved to another
+// block by a reorg while waiting, the confirmations are counted again.
+func WaitMined(ctx context.Context, backend Backend, txHash common.Hash, confirmations uint64, pollingInterval time.Duration, timeout time.Duration) (*types.Receipt, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	if confirmations == 0 {
+		confirmations = 1
+	}
+
+	for {
+		receipt, err := backend.TransactionReceipt(ctx, txHash)
+		if err != nil {
+			if !errors.Is(err, ethereum.NotFound) {
+				return nil, err
+			}
+		} else if receipt != nil {
+			number, err := backend.BlockNumber(ctx)
+			if err != nil {
+				return nil, err
+			}
+
+			if number+1 >= receipt.BlockNumber.Uint64()+confirmations {
+				return receipt, nil
+			}
+		}
+
+		select {
+		case <-time.After(pollingInterval):
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, fmt.Errorf("%w: %x", ErrWaitTimeout, txHash)
+			}
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// SuggestDynamicFees suggests the tip and fee cap for an eip1559 transaction.
+// The fee cap allows the base fee to double before the transaction is no
+// longer includable. It returns ErrLondonNotActive if the latest block has no
+// base fee.
+func SuggestDynamicFees(ctx context.Context, backend Backend) (gasTipCap *big.Int, gasFeeCap *big.Int, err error) {
+	header, err := backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if header.BaseFee == nil {
+		return nil, nil, ErrLondonNotActive
+	}
+
+	gasTipCap, err = backend.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	gasFeeCap = new(big.Int).Add(gasTipCap, new(big.Int).Mul(header.BaseFee, big.NewInt(2)))
+	return gasTipCap, gasFeeCap, nil
+}
+
 // ParseABIUnchecked will parse a valid json abi. Only use this with string constants known to be correct.
 func ParseABIUnchecked(json string) abi.ABI {
 	cabi, err := abi.JSON(strings.NewReader(json))
@@ -0,0 +1,155 @@
+package transaction
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+var (
+	// ErrNoPublicKey is returned by remote signers, which only expose addresses.
+	ErrNoPublicKey = errors.New("remote signer does not expose public keys")
+	// ErrUnknownAccount is returned when the remote signer does not manage the account.
+	ErrUnknownAccount = errors.New("account not managed by remote signer")
+	// ErrRemoteTxModified is returned when the remote signer signed a different transaction than requested.
+	ErrRemoteTxModified = errors.New("remote signer modified the transaction")
+)
+
+// SignTransactionResult is the result of account_signTransaction.
+type SignTransactionResult struct {
+	Raw hexutil.Bytes      `json:"raw"`
+	Tx  *types.Transaction `json:"tx"`
+}
+
+// clefSigner delegates signing to an external signer speaking Clef's
+// account_* json-rpc api, so the key never enters this process.
+type clefSigner struct {
+	client  *rpc.Client
+	address common.Address
+}
+
+// NewClefSigner returns a signer for address using the remote signer behind client.
+func NewClefSigner(client *rpc.Client, address common.Address) Signer {
+	return &clefSigner{
+		client:  client,
+		address: address,
+	}
+}
+
+// DialClefSigner connects to the remote signer at endpoint, e.g. Clef's ipc
+// socket or http address, and checks that it manages address.
+func DialClefSigner(endpoint string, address common.Address) (Signer, error) {
+	client, err := rpc.Dial(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []common.Address
+	err = client.Call(&list, "account_list")
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	for _, a := range list {
+		if a == address {
+			return NewClefSigner(client, address), nil
+		}
+	}
+
+	client.Close()
+	return nil, ErrUnknownAccount
+}
+
+// PublicKey is not supported by remote signers.
+func (c *clefSigner) PublicKey() (*ecdsa.PublicKey, error) {
+	return nil, ErrNoPublicKey
+}
+
+// EthereumAddress returns the ethereum address this signer uses.
+func (c *clefSigner) EthereumAddress() (common.Address, error) {
+	return c.address, nil
+}
+
+// Sign signs data with ethereum prefix (eip191 type 0x45) using account_signData.
+func (c *clefSigner) Sign(data []byte) ([]byte, error) {
+	var signature hexutil.Bytes
+	err := c.client.Call(&signature, "account_signData", accounts.MimetypeTextPlain, c.account(), hexutil.Encode(data))
+	if err != nil {
+		return nil, err
+	}
+	return signature, nil
+}
+
+// SignTx signs an ethereum transaction using account_signTransaction.
+func (c *clefSigner) SignTx(transaction *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	data := hexutil.Bytes(transaction.Data())
+	args := apitypes.SendTxArgs{
+		From:    common.NewMixedcaseAddress(c.address),
+		Gas:     hexutil.Uint64(transaction.Gas()),
+		Value:   hexutil.Big(*transaction.Value()),
+		Nonce:   hexutil.Uint64(transaction.Nonce()),
+		Data:    &data,
+		ChainID: (*hexutil.Big)(chainID),
+	}
+	if transaction.To() != nil {
+		to := common.NewMixedcaseAddress(*transaction.To())
+		args.To = &to
+	}
+	if transaction.Type() == types.DynamicFeeTxType {
+		args.MaxFeePerGas = (*hexutil.Big)(transaction.GasFeeCap())
+		args.MaxPriorityFeePerGas = (*hexutil.Big)(transaction.GasTipCap())
+	} else {
+		args.GasPrice = (*hexutil.Big)(transaction.GasPrice())
+	}
+
+	var result SignTransactionResult
+	err := c.client.Call(&result, "account_signTransaction", &args)
+	if err != nil {
+		return nil, err
+	}
+
+	signedTx := new(types.Transaction)
+	if err := signedTx.UnmarshalBinary(result.Raw); err != nil {
+		return nil, err
+	}
+
+	// the remote side may change transactions, we only accept what we asked for
+	txSigner := types.LatestSignerForChainID(chainID)
+	if txSigner.Hash(signedTx) != txSigner.Hash(transaction) {
+		return nil, ErrRemoteTxModified
+	}
+	sender, err := types.Sender(txSigner, signedTx)
+	if err != nil {
+		return nil, err
+	}
+	if sender != c.address {
+		return nil, fmt.Errorf("remote signer signed with %x: %w", sender, ErrWrongSigner)
+	}
+
+	return signedTx, nil
+}
+
+// SignTypedData signs message of type primaryType in domain according to eip712 using account_signTypedData.
+func (c *clefSigner) SignTypedData(domain TypedDataDomain, types Types, primaryType string, message TypedDataMessage) ([]byte, error) {
+	var signature hexutil.Bytes
+	err := c.client.Call(&signature, "account_signTypedData", c.account(), NewTypedData(domain, types, primaryType, message))
+	if err != nil {
+		return nil, err
+	}
+	return signature, nil
+}
+
+// account returns the address in the form the api expects. MixedcaseAddress
+// only marshals to json through a pointer.
+func (c *clefSigner) account() *common.MixedcaseAddress {
+	account := common.NewMixedcaseAddress(c.address)
+	return &account
+}
@@ -0,0 +1,246 @@
+package transaction
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// modifyingClefService signs a different transaction than requested.
+type modifyingClefService struct {
+	*ClefService
+}
+
+func (s *modifyingClefService) SignTransaction(args apitypes.SendTxArgs, methodSelector *string) (*SignTransactionResult, error) {
+	args.Nonce++
+	return s.ClefService.SignTransaction(args, methodSelector)
+}
+
+// newTestClefSigner serves service over http and returns a clefSigner for address using it.
+func newTestClefSigner(t *testing.T, service interface{}, address common.Address) Signer {
+	t.Helper()
+
+	server := rpc.NewServer()
+	if err := server.RegisterName("account", service); err != nil {
+		t.Fatal(err)
+	}
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+
+	client, err := rpc.DialHTTP(httpServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Close)
+
+	return NewClefSigner(client, address)
+}
+
+func newTestKey(t *testing.T, seed string) Signer {
+	t.Helper()
+
+	key, err := crypto.ToECDSA(crypto.Keccak256([]byte(seed)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewDefaultSigner(key)
+}
+
+func TestClefSigner(t *testing.T) {
+	chainID := big.NewInt(5)
+	local := newTestKey(t, "clef")
+	address, err := local.EthereumAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service, err := NewClefService(local, chainID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := NewClefServer(service)
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	remote, err := DialClefSigner(httpServer.URL, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	to := common.HexToAddress("0x1234567890")
+	for _, tc := range []struct {
+		name string
+		tx   *types.Transaction
+	}{
+		{
+			name: "legacy",
+			tx: types.NewTx(&types.LegacyTx{
+				Nonce:    3,
+				GasPrice: big.NewInt(1000000000),
+				Gas:      21000,
+				To:       &to,
+				Value:    big.NewInt(1),
+				Data:     []byte{1, 2, 3},
+			}),
+		},
+		{
+			name: "dynamic fee",
+			tx: types.NewTx(&types.DynamicFeeTx{
+				ChainID:   chainID,
+				Nonce:     4,
+				GasTipCap: big.NewInt(1000000000),
+				GasFeeCap: big.NewInt(3000000000),
+				Gas:       21000,
+				To:        &to,
+				Value:     big.NewInt(1),
+			}),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			want, err := local.SignTx(tc.tx, chainID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := remote.SignTx(tc.tx, chainID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Hash() != want.Hash() {
+				t.Fatalf("got signed tx %x, want %x", got.Hash(), want.Hash())
+			}
+		})
+	}
+
+	t.Run("sign", func(t *testing.T) {
+		data := []byte("hello clef")
+		want, err := local.Sign(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := remote.Sign(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("got signature %x, want %x", got, want)
+		}
+	})
+
+	t.Run("typed data", func(t *testing.T) {
+		domain := TypedDataDomain{
+			Name:    "Chequebook",
+			Version: "1.0",
+			ChainId: math.NewHexOrDecimal256(chainID.Int64()),
+		}
+		chequeTypes := Types{
+			"Cheque": []Type{
+				{Name: "chequebook", Type: "address"},
+				{Name: "beneficiary", Type: "address"},
+				{Name: "cumulativePayout", Type: "uint256"},
+			},
+		}
+		message := TypedDataMessage{
+			"chequebook":       "0xfa02D396842E6e1D319E8E3D4D870338F791AA25",
+			"beneficiary":      "0x98E6C644aFeB94BBfB9FF60EB26fc9D83BBEcA79",
+			"cumulativePayout": "500",
+		}
+		want, err := local.SignTypedData(domain, chequeTypes, "Cheque", message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := remote.SignTypedData(domain, chequeTypes, "Cheque", message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("got signature %x, want %x", got, want)
+		}
+	})
+}
+
+func TestDialClefSignerUnknownAccount(t *testing.T) {
+	service, err := NewClefService(newTestKey(t, "clef"), big.NewInt(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := NewClefServer(service)
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	_, err = DialClefSigner(httpServer.URL, common.HexToAddress("0x1234567890"))
+	if !errors.Is(err, ErrUnknownAccount) {
+		t.Fatalf("got error %v, want %v", err, ErrUnknownAccount)
+	}
+}
+
+func TestClefSignerRemoteTxModified(t *testing.T) {
+	chainID := big.NewInt(5)
+	local := newTestKey(t, "clef")
+	address, err := local.EthereumAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	service, err := NewClefService(local, chainID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	remote := newTestClefSigner(t, &modifyingClefService{service}, address)
+
+	to := common.HexToAddress("0x1234567890")
+	tx := types.NewTx(&types.LegacyTx{
+		Nonce:    3,
+		GasPrice: big.NewInt(1000000000),
+		Gas:      21000,
+		To:       &to,
+		Value:    big.NewInt(1),
+	})
+	_, err = remote.SignTx(tx, chainID)
+	if !errors.Is(err, ErrRemoteTxModified) {
+		t.Fatalf("got error %v, want %v", err, ErrRemoteTxModified)
+	}
+}
+
+func TestClefSignerWrongSigner(t *testing.T) {
+	chainID := big.NewInt(5)
+	address, err := newTestKey(t, "clef").EthereumAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the service claims address but signs with another key
+	service := &ClefService{
+		signer:  newTestKey(t, "other"),
+		address: address,
+		chainID: chainID,
+	}
+	remote := newTestClefSigner(t, service, address)
+
+	to := common.HexToAddress("0x1234567890")
+	tx := types.NewTx(&types.LegacyTx{
+		Nonce:    3,
+		GasPrice: big.NewInt(1000000000),
+		Gas:      21000,
+		To:       &to,
+		Value:    big.NewInt(1),
+	})
+	_, err = remote.SignTx(tx, chainID)
+	if !errors.Is(err, ErrWrongSigner) {
+		t.Fatalf("got error %v, want %v", err, ErrWrongSigner)
+	}
+}
@@ -0,0 +1,123 @@
+package transaction
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// clefAPIVersion is the version of Clef's external api the ClefService implements.
+const clefAPIVersion = "6.1.0"
+
+// ErrUnsupportedContentType is returned for account_signData requests other than text/plain.
+var ErrUnsupportedContentType = errors.New("unsupported content type")
+
+// ClefService is a stand-in for Clef that serves a local Signer over the
+// account_* json-rpc api, e.g. for development and tests. Unlike Clef it has
+// no approval rules, it signs everything for its account.
+type ClefService struct {
+	signer  Signer
+	address common.Address
+	chainID *big.Int // used for legacy transactions, which carry no chain id
+}
+
+// NewClefService creates the service for the account of signer.
+func NewClefService(signer Signer, chainID *big.Int) (*ClefService, error) {
+	address, err := signer.EthereumAddress()
+	if err != nil {
+		return nil, err
+	}
+	return &ClefService{
+		signer:  signer,
+		address: address,
+		chainID: chainID,
+	}, nil
+}
+
+// NewClefServer returns an rpc server serving service in the account namespace.
+func NewClefServer(service *ClefService) (*rpc.Server, error) {
+	server := rpc.NewServer()
+	if err := server.RegisterName("account", service); err != nil {
+		return nil, err
+	}
+	return server, nil
+}
+
+// Version implements account_version.
+func (s *ClefService) Version() string {
+	return clefAPIVersion
+}
+
+// List implements account_list.
+func (s *ClefService) List() []common.Address {
+	return []common.Address{s.address}
+}
+
+// SignTransaction implements account_signTransaction.
+func (s *ClefService) SignTransaction(args apitypes.SendTxArgs, methodSelector *string) (*SignTransactionResult, error) {
+	if err := s.checkAccount(args.From); err != nil {
+		return nil, err
+	}
+
+	chainID := s.chainID
+	if args.ChainID != nil {
+		chainID = args.ChainID.ToInt()
+	}
+
+	signedTx, err := s.signer.SignTx(args.ToTransaction(), chainID)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := signedTx.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return &SignTransactionResult{
+		Raw: raw,
+		Tx:  signedTx,
+	}, nil
+}
+
+// SignData implements account_signData for text/plain data, which is signed
+// with the ethereum prefix (eip191 type 0x45).
+func (s *ClefService) SignData(contentType string, addr common.MixedcaseAddress, data interface{}) (hexutil.Bytes, error) {
+	if contentType != accounts.MimetypeTextPlain {
+		return nil, fmt.Errorf("%w %s", ErrUnsupportedContentType, contentType)
+	}
+	if err := s.checkAccount(addr); err != nil {
+		return nil, err
+	}
+
+	encoded, ok := data.(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid data %v", data)
+	}
+	message, err := hexutil.Decode(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.signer.Sign(message)
+}
+
+// SignTypedData implements account_signTypedData.
+func (s *ClefService) SignTypedData(addr common.MixedcaseAddress, typedData TypedData) (hexutil.Bytes, error) {
+	if err := s.checkAccount(addr); err != nil {
+		return nil, err
+	}
+	return s.signer.SignTypedData(typedData.Domain, typedData.Types, typedData.PrimaryType, typedData.Message)
+}
+
+func (s *ClefService) checkAccount(addr common.MixedcaseAddress) error {
+	if addr.Address() != s.address {
+		return ErrUnknownAccount
+	}
+	return nil
+}
@@ -22,6 +22,8 @@ type Signer interface {
 	Sign(data []byte) ([]byte, error)
 	// SignTx signs an ethereum transaction.
 	SignTx(transaction *types.Transaction, chainID *big.Int) (*types.Transaction, error)
+	// SignTypedData signs message of type primaryType in domain according to eip712.
+	SignTypedData(domain TypedDataDomain, types Types, primaryType string, message TypedDataMessage) ([]byte, error)
 	// PublicKey returns the public key this signer uses.
 	PublicKey() (*ecdsa.PublicKey, error)
 	// EthereumAddress returns the ethereum address this signer uses.
@@ -51,6 +53,18 @@ func hashWithEthereumPrefix(data []byte) ([]byte, error) {
 // Recover verifies signature with the data base provided.
 // It is using `btcec.RecoverCompact` function.
 func Recover(signature, data []byte) (*ecdsa.PublicKey, error) {
+	hash, err := hashWithEthereumPrefix(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return RecoverHash(signature, hash)
+}
+
+// RecoverHash recovers the public key from a signature over an already hashed
+// message, e.g. an eip712 digest. The signature is expected in the (r,s,v)
+// format with v being 27 or 28.
+func RecoverHash(signature, hash []byte) (*ecdsa.PublicKey, error) {
 	if len(signature) != 65 {
 		return nil, ErrInvalidLength
 	}
@@ -59,11 +73,6 @@ func Recover(signature, data []byte) (*ecdsa.PublicKey, error) {
 	btcsig[0] = signature[64]
 	copy(btcsig[1:], signature)
 
-	hash, err := hashWithEthereumPrefix(data)
-	if err != nil {
-		return nil, err
-	}
-
 	p, _, err := btcec.RecoverCompact(btcec.S256(), btcsig, hash)
 	return (*ecdsa.PublicKey)(p), err
 }
@@ -93,9 +102,9 @@ func (d *defaultSigner) Sign(data []byte) (signature []byte, err error) {
 	return d.sign(hash, true)
 }
 
-// SignTx signs an ethereum transaction.
+// SignTx signs an ethereum transaction. Legacy and dynamic fee transactions are supported.
 func (d *defaultSigner) SignTx(transaction *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
-	txSigner := types.NewEIP155Signer(chainID)
+	txSigner := types.LatestSignerForChainID(chainID)
 	hash := txSigner.Hash(transaction).Bytes()
 	// isCompressedKey is false here so we get the expected v value (27 or 28)
 	signature, err := d.sign(hash, false)
@@ -108,6 +117,16 @@ func (d *defaultSigner) SignTx(transaction *types.Transaction, chainID *big.Int)
 	return transaction.WithSignature(txSigner, signature)
 }
 
+// SignTypedData signs message of type primaryType in domain according to eip712.
+func (d *defaultSigner) SignTypedData(domain TypedDataDomain, types Types, primaryType string, message TypedDataMessage) ([]byte, error) {
+	sighash, err := HashTypedData(NewTypedData(domain, types, primaryType, message))
+	if err != nil {
+		return nil, err
+	}
+
+	return d.sign(sighash, false)
+}
+
 // EthereumAddress returns the ethereum address this signer uses.
 func (d *defaultSigner) EthereumAddress() (common.Address, error) {
 	publicKey, err := d.PublicKey()
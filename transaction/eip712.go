@@ -0,0 +1,135 @@
+package transaction
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core"
+)
+
+// TypedDataDomain is the domain of an eip712 typed data message.
+type TypedDataDomain = core.TypedDataDomain
+
+// Types describes the struct types of an eip712 typed data message.
+type Types = core.Types
+
+// TypedData is an eip712 typed data message together with its domain and types.
+type TypedData = core.TypedData
+
+// TypedDataMessage is the message part of an eip712 typed data.
+type TypedDataMessage = core.TypedDataMessage
+
+// Type is a single field of an eip712 struct type.
+type Type = core.Type
+
+// EIP712DomainType is the type description for the EIP712Domain used by the
+// chequebook contracts (name, version and chainId).
+var EIP712DomainType = []Type{
+	{
+		Name: "name",
+		Type: "string",
+	},
+	{
+		Name: "version",
+		Type: "string",
+	},
+	{
+		Name: "chainId",
+		Type: "uint256",
+	},
+}
+
+// EncodeForSigning encodes the hash that will be signed for the given EIP712 data.
+func EncodeForSigning(typedData *TypedData) ([]byte, error) {
+	domainSeparator, err := typedData.HashStruct("EIP712Domain", typedData.Domain.Map())
+	if err != nil {
+		return nil, err
+	}
+	typedDataHash, err := typedData.HashStruct(typedData.PrimaryType, typedData.Message)
+	if err != nil {
+		return nil, err
+	}
+	rawData := []byte(fmt.Sprintf("\x19\x01%s%s", string(domainSeparator), string(typedDataHash)))
+	return rawData, nil
+}
+
+// HashTypedData returns the eip712 digest of the typed data, i.e. the hash
+// that is actually signed.
+func HashTypedData(typedData *TypedData) ([]byte, error) {
+	rawData, err := EncodeForSigning(typedData)
+	if err != nil {
+		return nil, err
+	}
+	return LegacyKeccak256(rawData)
+}
+
+// NewTypedData assembles eip712 typed data. If types has no EIP712Domain
+// entry, it is derived from the fields set in domain.
+func NewTypedData(domain TypedDataDomain, types Types, primaryType string, message TypedDataMessage) *TypedData {
+	if _, ok := types["EIP712Domain"]; !ok {
+		withDomain := make(Types, len(types)+1)
+		for name, fields := range types {
+			withDomain[name] = fields
+		}
+		withDomain["EIP712Domain"] = domainType(domain)
+		types = withDomain
+	}
+
+	return &TypedData{
+		Types:       types,
+		PrimaryType: primaryType,
+		Domain:      domain,
+		Message:     message,
+	}
+}
+
+// domainType returns the EIP712Domain type for the fields set in domain, in
+// the order eip712 defines.
+func domainType(domain TypedDataDomain) []Type {
+	var fields []Type
+	if domain.Name != "" {
+		fields = append(fields, Type{Name: "name", Type: "string"})
+	}
+	if domain.Version != "" {
+		fields = append(fields, Type{Name: "version", Type: "string"})
+	}
+	if domain.ChainId != nil {
+		fields = append(fields, Type{Name: "chainId", Type: "uint256"})
+	}
+	if domain.VerifyingContract != "" {
+		fields = append(fields, Type{Name: "verifyingContract", Type: "address"})
+	}
+	if domain.Salt != "" {
+		fields = append(fields, Type{Name: "salt", Type: "bytes32"})
+	}
+	return fields
+}
+
+// RecoverTypedData recovers the public key which signed the typed data. The
+// signature is expected in the (r,s,v) format, v may be 27/28 or 0/1.
+func RecoverTypedData(signature []byte, typedData *TypedData) (*ecdsa.PublicKey, error) {
+	if len(signature) != 65 {
+		return nil, ErrInvalidLength
+	}
+	hash, err := HashTypedData(typedData)
+	if err != nil {
+		return nil, err
+	}
+
+	if signature[64] < 27 {
+		signature = append([]byte(nil), signature...)
+		signature[64] += 27
+	}
+	return RecoverHash(signature, hash)
+}
+
+// RecoverTypedDataAddress returns the address of the account which signed the typed data.
+func RecoverTypedDataAddress(signature []byte, typedData *TypedData) (common.Address, error) {
+	pubKey, err := RecoverTypedData(signature, typedData)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*pubKey), nil
+}
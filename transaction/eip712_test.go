@@ -0,0 +1,196 @@
+package transaction
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// checkTypedDataSignature checks that signature over the typed data recovers
+// to want, with v being 27/28 as well as 0/1.
+func checkTypedDataSignature(t *testing.T, signature []byte, typedData *TypedData, want common.Address) {
+	t.Helper()
+
+	if len(signature) != 65 {
+		t.Fatalf("got signature of length %d, want 65", len(signature))
+	}
+	if v := signature[64]; v != 27 && v != 28 {
+		t.Fatalf("got v %d, want 27 or 28", v)
+	}
+
+	legacy := append([]byte(nil), signature...)
+	legacy[64] -= 27
+	for _, sig := range [][]byte{signature, legacy} {
+		recovered, err := RecoverTypedDataAddress(sig, typedData)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if recovered != want {
+			t.Fatalf("v %d: recovered %s, want %s", sig[64], recovered.Hex(), want.Hex())
+		}
+	}
+}
+
+// TestTypedDataMail checks the example of eip712 itself.
+func TestTypedDataMail(t *testing.T) {
+	domain := TypedDataDomain{
+		Name:              "Ether Mail",
+		Version:           "1",
+		ChainId:           math.NewHexOrDecimal256(1),
+		VerifyingContract: "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC",
+	}
+	types := Types{
+		"Person": []Type{
+			{Name: "name", Type: "string"},
+			{Name: "wallet", Type: "address"},
+		},
+		"Mail": []Type{
+			{Name: "from", Type: "Person"},
+			{Name: "to", Type: "Person"},
+			{Name: "contents", Type: "string"},
+		},
+	}
+	message := TypedDataMessage{
+		"from": map[string]interface{}{
+			"name":   "Cow",
+			"wallet": "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826",
+		},
+		"to": map[string]interface{}{
+			"name":   "Bob",
+			"wallet": "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB",
+		},
+		"contents": "Hello, Bob!",
+	}
+	typedData := NewTypedData(domain, types, "Mail", message)
+
+	digest, err := HashTypedData(typedData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantDigest := hexutil.MustDecode("0xbe609aee343fb3c4b28e1df9e632fca64fcfaede20f02e86244efddf30957bd2")
+	if !bytes.Equal(digest, wantDigest) {
+		t.Fatalf("got digest %x, want %x", digest, wantDigest)
+	}
+
+	key, err := crypto.ToECDSA(crypto.Keccak256([]byte("cow")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := NewDefaultSigner(key)
+	want := common.HexToAddress("0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826")
+	if address, err := signer.EthereumAddress(); err != nil || address != want {
+		t.Fatalf("got address %s (%v), want %s", address.Hex(), err, want.Hex())
+	}
+
+	signature, err := signer.SignTypedData(domain, types, "Mail", message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTypedDataSignature(t, signature, typedData, want)
+
+	// the signature given in eip712
+	specSignature := hexutil.MustDecode("0x4355c47d63924e8a72e509b65029052eb6c299d53a04e167c5775fd466751c9d07299936d304c153f6443dfa05f40ff007d72911b6f72307f996231605b915621c")
+	checkTypedDataSignature(t, specSignature, typedData, want)
+}
+
+// TestTypedDataChequebook checks the digests of the messages signed for the
+// chequebook contract. The types mirror the ones in conAbi, the digests were
+// computed independently by abi encoding the structs by hand.
+func TestTypedDataChequebook(t *testing.T) {
+	domain := TypedDataDomain{
+		Name:    "Chequebook",
+		Version: "1.0",
+		ChainId: math.NewHexOrDecimal256(5),
+	}
+	chequebook := "0xfa02D396842E6e1D319E8E3D4D870338F791AA25"
+	beneficiary := "0x98E6C644aFeB94BBfB9FF60EB26fc9D83BBEcA79"
+
+	for _, tc := range []struct {
+		name        string
+		primaryType string
+		fields      []Type
+		message     TypedDataMessage
+		digest      string
+	}{
+		{
+			name:        "cheque",
+			primaryType: "Cheque",
+			fields: []Type{
+				{Name: "chequebook", Type: "address"},
+				{Name: "beneficiary", Type: "address"},
+				{Name: "cumulativePayout", Type: "uint256"},
+			},
+			message: TypedDataMessage{
+				"chequebook":       chequebook,
+				"beneficiary":      beneficiary,
+				"cumulativePayout": "500",
+			},
+			digest: "0xc46ee0ed85be9085efe21f557e973170d4c3d59b05674f4c24351b83cd081a3b",
+		},
+		{
+			name:        "cashout",
+			primaryType: "Cashout",
+			fields: []Type{
+				{Name: "chequebook", Type: "address"},
+				{Name: "sender", Type: "address"},
+				{Name: "requestPayout", Type: "uint256"},
+				{Name: "recipient", Type: "address"},
+				{Name: "callerPayout", Type: "uint256"},
+			},
+			message: TypedDataMessage{
+				"chequebook":    chequebook,
+				"sender":        "0x1111111111111111111111111111111111111111",
+				"requestPayout": "500",
+				"recipient":     "0x2222222222222222222222222222222222222222",
+				"callerPayout":  "10",
+			},
+			digest: "0x5ee7b2c8ffd0bdbd0f51ac11314945d1d98e9a5dc751b22df12f5923896fa312",
+		},
+		{
+			name:        "custom timeout",
+			primaryType: "CustomDecreaseTimeout",
+			fields: []Type{
+				{Name: "chequebook", Type: "address"},
+				{Name: "beneficiary", Type: "address"},
+				{Name: "decreaseTimeout", Type: "uint256"},
+			},
+			message: TypedDataMessage{
+				"chequebook":      chequebook,
+				"beneficiary":     beneficiary,
+				"decreaseTimeout": "86400",
+			},
+			digest: "0x0c1c81d3dfa3e8e46762a2af04e34f619085049490b740c2e4409ac66b295a8d",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			types := Types{
+				"EIP712Domain": EIP712DomainType,
+				tc.primaryType: tc.fields,
+			}
+			typedData := NewTypedData(domain, types, tc.primaryType, tc.message)
+
+			digest, err := HashTypedData(typedData)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := hexutil.MustDecode(tc.digest); !bytes.Equal(digest, want) {
+				t.Fatalf("got digest %x, want %x", digest, want)
+			}
+
+			signer := newTestKey(t, "chequebook")
+			address, err := signer.EthereumAddress()
+			if err != nil {
+				t.Fatal(err)
+			}
+			signature, err := signer.SignTypedData(domain, types, tc.primaryType, tc.message)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkTypedDataSignature(t, signature, typedData, address)
+		})
+	}
+}
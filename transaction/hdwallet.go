@@ -0,0 +1,172 @@
+package transaction
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/tyler-smith/go-bip39"
+)
+
+var (
+	// ErrInvalidMnemonic is returned for mnemonics with unknown words or a wrong checksum.
+	ErrInvalidMnemonic = errors.New("invalid mnemonic")
+	// ErrInvalidChildKey is returned for the rare indexes bip32 yields no valid key for.
+	ErrInvalidChildKey = errors.New("invalid child key, use the next index")
+	// ErrAccountNotFound is returned when no derived account matches the address.
+	ErrAccountNotFound = errors.New("account not derived from this mnemonic")
+	// ErrInvalidAccountCount is returned when a negative or too large number of accounts is requested.
+	ErrInvalidAccountCount = errors.New("invalid number of accounts")
+)
+
+const (
+	// mnemonicEntropyBits is the entropy of generated mnemonics, 24 words.
+	mnemonicEntropyBits = 256
+	// hardenedKeyStart is the first index of hardened child keys.
+	hardenedKeyStart = 0x80000000
+)
+
+// extendedKey is a bip32 private key with its chain code.
+type extendedKey struct {
+	key       *big.Int
+	chainCode []byte
+}
+
+// HDWallet derives keys from a bip39 mnemonic according to bip32. Accounts
+// use the bip44 path m/44'/60'/0'/0/i.
+type HDWallet struct {
+	master extendedKey
+}
+
+// NewMnemonic generates a new random 24 word mnemonic.
+func NewMnemonic() (string, error) {
+	entropy, err := bip39.NewEntropy(mnemonicEntropyBits)
+	if err != nil {
+		return "", err
+	}
+	return bip39.NewMnemonic(entropy)
+}
+
+// NewHDWallet creates a wallet from a mnemonic and an optional bip39 passphrase.
+func NewHDWallet(mnemonic, passphrase string) (*HDWallet, error) {
+	// IsMnemonicValid only checks the words, EntropyFromMnemonic also the checksum
+	if _, err := bip39.EntropyFromMnemonic(mnemonic); err != nil {
+		return nil, ErrInvalidMnemonic
+	}
+	return newHDWallet(bip39.NewSeed(mnemonic, passphrase))
+}
+
+// newHDWallet creates a wallet from a bip32 seed.
+func newHDWallet(seed []byte) (*HDWallet, error) {
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+
+	key := new(big.Int).SetBytes(sum[:32])
+	if key.Sign() == 0 || key.Cmp(btcec.S256().N) >= 0 {
+		return nil, ErrInvalidChildKey
+	}
+
+	return &HDWallet{
+		master: extendedKey{key: key, chainCode: sum[32:]},
+	}, nil
+}
+
+// AccountPath returns the derivation path of account index, m/44'/60'/0'/0/index.
+func AccountPath(index uint32) accounts.DerivationPath {
+	path := make(accounts.DerivationPath, len(accounts.DefaultBaseDerivationPath))
+	copy(path, accounts.DefaultBaseDerivationPath)
+	return append(path[:len(path)-1], index)
+}
+
+// Derive returns the private key at path.
+func (w *HDWallet) Derive(path accounts.DerivationPath) (*ecdsa.PrivateKey, error) {
+	k := w.master
+	for _, index := range path {
+		var err error
+		k, err = k.child(index)
+		if err != nil {
+			return nil, fmt.Errorf("derive %s: %w", path, err)
+		}
+	}
+	return crypto.ToECDSA(common.LeftPadBytes(k.key.Bytes(), 32))
+}
+
+// Signer returns a signer for account index.
+func (w *HDWallet) Signer(index uint32) (Signer, error) {
+	key, err := w.Derive(AccountPath(index))
+	if err != nil {
+		return nil, err
+	}
+	return NewDefaultSigner(key), nil
+}
+
+// Addresses returns the addresses of the first n accounts.
+func (w *HDWallet) Addresses(n int) ([]common.Address, error) {
+	// account indexes from hardenedKeyStart on do not fit the non hardened last path element
+	if n < 0 || n > hardenedKeyStart {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidAccountCount, n)
+	}
+
+	addresses := make([]common.Address, 0, n)
+	for i := 0; i < n; i++ {
+		key, err := w.Derive(AccountPath(uint32(i)))
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, crypto.PubkeyToAddress(key.PublicKey))
+	}
+	return addresses, nil
+}
+
+// FindSigner returns the signer for address, searching the first n accounts.
+func (w *HDWallet) FindSigner(address common.Address, n int) (Signer, error) {
+	addresses, err := w.Addresses(n)
+	if err != nil {
+		return nil, err
+	}
+	for i, a := range addresses {
+		if a == address {
+			return w.Signer(uint32(i))
+		}
+	}
+	return nil, ErrAccountNotFound
+}
+
+// child derives the child key at index, indexes from 2^31 on are hardened.
+func (k extendedKey) child(index uint32) (extendedKey, error) {
+	var data []byte
+	if index >= hardenedKeyStart {
+		data = append([]byte{0}, common.LeftPadBytes(k.key.Bytes(), 32)...)
+	} else {
+		_, pub := btcec.PrivKeyFromBytes(btcec.S256(), k.key.Bytes())
+		data = pub.SerializeCompressed()
+	}
+	data = append(data, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(data[len(data)-4:], index)
+
+	mac := hmac.New(sha512.New, k.chainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	n := btcec.S256().N
+	tweak := new(big.Int).SetBytes(sum[:32])
+	if tweak.Cmp(n) >= 0 {
+		return extendedKey{}, ErrInvalidChildKey
+	}
+	key := tweak.Add(tweak, k.key)
+	key.Mod(key, n)
+	if key.Sign() == 0 {
+		return extendedKey{}, ErrInvalidChildKey
+	}
+
+	return extendedKey{key: key, chainCode: sum[32:]}, nil
+}
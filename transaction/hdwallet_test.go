@@ -0,0 +1,173 @@
+package transaction
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// base58Alphabet is the bitcoin base58 alphabet used by serialized extended keys.
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// decodeExtendedKey decodes a base58check serialized bip32 private key into
+// its chain code and key.
+func decodeExtendedKey(t *testing.T, xprv string) (chainCode, key []byte) {
+	t.Helper()
+
+	n := new(big.Int)
+	for _, c := range xprv {
+		i := bytes.IndexRune([]byte(base58Alphabet), c)
+		if i < 0 {
+			t.Fatalf("invalid base58 character %q", c)
+		}
+		n.Mul(n, big.NewInt(58))
+		n.Add(n, big.NewInt(int64(i)))
+	}
+
+	// version, depth, fingerprint, child number, chain code, 0x00 || key, checksum
+	data := common.LeftPadBytes(n.Bytes(), 82)
+	first := sha256.Sum256(data[:78])
+	second := sha256.Sum256(first[:])
+	if !bytes.Equal(second[:4], data[78:]) {
+		t.Fatalf("invalid checksum of %s", xprv)
+	}
+	if data[45] != 0 {
+		t.Fatalf("%s is not a private key", xprv)
+	}
+	return data[13:45], data[46:78]
+}
+
+// TestBIP32Vector1 checks the derivation against test vector 1 of bip32.
+func TestBIP32Vector1(t *testing.T) {
+	seed, err := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wallet, err := newHDWallet(seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		path string
+		xprv string
+	}{
+		{
+			path: "m",
+			xprv: "xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi",
+		},
+		{
+			path: "m/0'",
+			xprv: "xprv9uHRZZhk6KAJC1avXpDAp4MDc3sQKNxDiPvvkX8Br5ngLNv1TxvUxt4cV1rGL5hj6KCesnDYUhd7oWgT11eZG7XnxHrnYeSvkzY7d2bhkJ7",
+		},
+		{
+			path: "m/0'/1",
+			xprv: "xprv9wTYmMFdV23N2TdNG573QoEsfRrWKQgWeibmLntzniatZvR9BmLnvSxqu53Kw1UmYPxLgboyZQaXwTCg8MSY3H2EU4pWcQDnRnrVA1xe8fs",
+		},
+		{
+			path: "m/0'/1/2'",
+			xprv: "xprv9z4pot5VBttmtdRTWfWQmoH1taj2axGVzFqSb8C9xaxKymcFzXBDptWmT7FwuEzG3ryjH4ktypQSAewRiNMjANTtpgP4mLTj34bhnZX7UiM",
+		},
+		{
+			path: "m/0'/1/2'/2",
+			xprv: "xprvA2JDeKCSNNZky6uBCviVfJSKyQ1mDYahRjijr5idH2WwLsEd4Hsb2Tyh8RfQMuPh7f7RtyzTtdrbdqqsunu5Mm3wDvUAKRHSC34sJ7in334",
+		},
+		{
+			path: "m/0'/1/2'/2/1000000000",
+			xprv: "xprvA41z7zogVVwxVSgdKUHDy1SKmdb533PjDz7J6N6mV6uS3ze1ai8FHa8kmHScGpWmj4WggLyQjgPie1rFSruoUihUZREPSL39UNdE3BBDu76",
+		},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			var path accounts.DerivationPath
+			if tc.path != "m" {
+				path, err = accounts.ParseDerivationPath(tc.path)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			k := wallet.master
+			for _, index := range path {
+				k, err = k.child(index)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			chainCode, key := decodeExtendedKey(t, tc.xprv)
+			if !bytes.Equal(k.chainCode, chainCode) {
+				t.Fatalf("got chain code %x, want %x", k.chainCode, chainCode)
+			}
+			if got := common.LeftPadBytes(k.key.Bytes(), 32); !bytes.Equal(got, key) {
+				t.Fatalf("got key %x, want %x", got, key)
+			}
+
+			derived, err := wallet.Derive(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := crypto.FromECDSA(derived); !bytes.Equal(got, key) {
+				t.Fatalf("derive returned key %x, want %x", got, key)
+			}
+		})
+	}
+}
+
+func TestHDWalletAccounts(t *testing.T) {
+	wallet, err := NewHDWallet("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := AccountPath(0).String(); got != "m/44'/60'/0'/0/0" {
+		t.Fatalf("got path %s, want m/44'/60'/0'/0/0", got)
+	}
+
+	addresses, err := wallet.Addresses(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := common.HexToAddress("0x9858EfFD232B4033E47d90003D41EC34EcaEda94")
+	if addresses[0] != want {
+		t.Fatalf("got address %s, want %s", addresses[0].Hex(), want.Hex())
+	}
+
+	signer, err := wallet.FindSigner(want, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	address, err := signer.EthereumAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if address != want {
+		t.Fatalf("got signer for %s, want %s", address.Hex(), want.Hex())
+	}
+}
+
+func TestHDWalletInvalidMnemonic(t *testing.T) {
+	_, err := NewHDWallet("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon", "")
+	if err != ErrInvalidMnemonic {
+		t.Fatalf("got error %v, want %v", err, ErrInvalidMnemonic)
+	}
+}
+
+func TestHDWalletInvalidAccountCount(t *testing.T) {
+	wallet, err := NewHDWallet("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = wallet.Addresses(-1)
+	if !errors.Is(err, ErrInvalidAccountCount) {
+		t.Fatalf("got error %v, want %v", err, ErrInvalidAccountCount)
+	}
+}
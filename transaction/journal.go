@@ -0,0 +1,301 @@
+package transaction
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"backend-demo/storage"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	journalEntryPrefix = "journal_tx_"
+	journalHashPrefix  = "journal_hash_"
+)
+
+// TxStatus is the state of a journaled transaction.
+type TxStatus string
+
+const (
+	TxStatusSigned    TxStatus = "signed"    // signed but not broadcast yet
+	TxStatusPending   TxStatus = "pending"   // broadcast, not mined yet
+	TxStatusMined     TxStatus = "mined"     // mined successfully
+	TxStatusReverted  TxStatus = "reverted"  // mined but reverted
+	TxStatusFailed    TxStatus = "failed"    // broadcast failed
+	TxStatusDropped   TxStatus = "dropped"   // the nonce was used by another transaction
+	TxStatusCancelled TxStatus = "cancelled" // a cancellation was mined instead
+)
+
+// ErrJournalEntryNotFound is returned for transactions which are not in the journal.
+var ErrJournalEntryNotFound = errors.New("journal entry not found")
+
+// JournalEntry is the record of a single transaction. It is keyed by the
+// hash of the first version, later replacements are appended to Replacements
+// and indexed so that the entry can be found by any of its hashes.
+type JournalEntry struct {
+	Sender       common.Address
+	Request      TxRequest
+	Nonce        uint64
+	Hash         common.Hash   // hash of the original transaction
+	Raw          hexutil.Bytes // signed raw bytes of the latest version
+	Replacements []common.Hash // hashes of replacements, the last one is the latest version
+	Cancels      []common.Hash `json:",omitempty"` // replacements which cancel the transaction, see Journal.Cancel
+	Status       TxStatus
+	MinedHash    common.Hash // hash of the version that was mined
+	Error        string      `json:",omitempty"`
+	Created      time.Time
+	Updated      time.Time
+}
+
+// IsFinal returns true if nothing will change for this transaction anymore.
+func (e *JournalEntry) IsFinal() bool {
+	return e.Status != TxStatusSigned && e.Status != TxStatusPending
+}
+
+// LatestHash returns the hash of the latest broadcast version of the transaction.
+func (e *JournalEntry) LatestHash() common.Hash {
+	if len(e.Replacements) > 0 {
+		return e.Replacements[len(e.Replacements)-1]
+	}
+	return e.Hash
+}
+
+// IsCancel returns true if txHash is a version which cancels the transaction.
+func (e *JournalEntry) IsCancel(txHash common.Hash) bool {
+	for _, hash := range e.Cancels {
+		if hash == txHash {
+			return true
+		}
+	}
+	return false
+}
+
+// Hashes returns the hashes of all versions of the transaction.
+func (e *JournalEntry) Hashes() []common.Hash {
+	return append([]common.Hash{e.Hash}, e.Replacements...)
+}
+
+// Transaction decodes the latest signed version of the transaction.
+func (e *JournalEntry) Transaction() (*types.Transaction, error) {
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(e.Raw); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// Journal durably records the transactions we send so that unfinished ones
+// can be resumed after a restart.
+type Journal struct {
+	mu    sync.Mutex
+	store storage.StateStorer
+}
+
+// NewJournal creates a journal on top of the given state store.
+func NewJournal(store storage.StateStorer) *Journal {
+	return &Journal{
+		store: store,
+	}
+}
+
+// Add records a newly signed transaction.
+func (j *Journal) Add(sender common.Address, request *TxRequest, signedTx *types.Transaction) error {
+	raw, err := signedTx.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	entry := &JournalEntry{
+		Sender:  sender,
+		Request: *request,
+		Nonce:   signedTx.Nonce(),
+		Hash:    signedTx.Hash(),
+		Raw:     raw,
+		Status:  TxStatusSigned,
+		Created: now,
+		Updated: now,
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.put(entry)
+}
+
+// Replace records that the transaction with txHash (any of its versions) was
+// replaced by replacement. Replacements of a cancelled transaction cancel it
+// as well.
+func (j *Journal) Replace(txHash common.Hash, replacement *types.Transaction) error {
+	return j.replace(txHash, replacement, false)
+}
+
+// Cancel records that the transaction with txHash (any of its versions) is
+// replaced by cancelTx, which uses the nonce without executing the transaction.
+func (j *Journal) Cancel(txHash common.Hash, cancelTx *types.Transaction) error {
+	return j.replace(txHash, cancelTx, true)
+}
+
+func (j *Journal) replace(txHash common.Hash, replacement *types.Transaction, cancel bool) error {
+	raw, err := replacement.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entry, err := j.get(txHash)
+	if err != nil {
+		return err
+	}
+
+	entry.Replacements = append(entry.Replacements, replacement.Hash())
+	if cancel || len(entry.Cancels) > 0 {
+		entry.Cancels = append(entry.Cancels, replacement.Hash())
+	}
+	entry.Raw = raw
+	if err := j.store.Put(journalHashPrefix+replacement.Hash().Hex(), entry.Hash); err != nil {
+		return err
+	}
+	return j.put(entry)
+}
+
+// SetStatus updates the status of the transaction with txHash (any of its
+// versions). minedHash is the version that was mined, if any.
+func (j *Journal) SetStatus(txHash common.Hash, status TxStatus, minedHash common.Hash, cause error) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entry, err := j.get(txHash)
+	if err != nil {
+		return err
+	}
+
+	entry.Status = status
+	entry.MinedHash = minedHash
+	if cause != nil {
+		entry.Error = cause.Error()
+	}
+	return j.put(entry)
+}
+
+// Get returns the entry of the transaction with txHash (any of its versions).
+func (j *Journal) Get(txHash common.Hash) (*JournalEntry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.get(txHash)
+}
+
+// Entries returns all journaled transactions, oldest first.
+func (j *Journal) Entries() ([]*JournalEntry, error) {
+	var entries []*JournalEntry
+	err := j.store.Iterate(journalEntryPrefix, func(key, value []byte) (bool, error) {
+		var entry JournalEntry
+		if err := json.Unmarshal(value, &entry); err != nil {
+			return true, fmt.Errorf("journal entry %s: %w", key, err)
+		}
+		entries = append(entries, &entry)
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(entries, func(i, k int) bool {
+		if entries[i].Created.Equal(entries[k].Created) {
+			return entries[i].Nonce < entries[k].Nonce
+		}
+		return entries[i].Created.Before(entries[k].Created)
+	})
+	return entries, nil
+}
+
+// Unfinished returns the transactions which are signed or pending, oldest first.
+func (j *Journal) Unfinished() ([]*JournalEntry, error) {
+	entries, err := j.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	var unfinished []*JournalEntry
+	for _, entry := range entries {
+		if !entry.IsFinal() {
+			unfinished = append(unfinished, entry)
+		}
+	}
+	return unfinished, nil
+}
+
+// Prune removes the entries of transactions which became final before the
+// given time and returns how many were removed.
+func (j *Journal) Prune(before time.Time) (int, error) {
+	entries, err := j.Entries()
+	if err != nil {
+		return 0, err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	pruned := 0
+	for _, entry := range entries {
+		if !entry.IsFinal() || !entry.Updated.Before(before) {
+			continue
+		}
+		for _, hash := range entry.Hashes() {
+			if err := j.store.Delete(journalHashPrefix + hash.Hex()); err != nil {
+				return pruned, err
+			}
+		}
+		if err := j.store.Delete(journalEntryPrefix + entry.Hash.Hex()); err != nil {
+			return pruned, err
+		}
+		pruned++
+	}
+	return pruned, nil
+}
+
+// get returns the entry of txHash. Entries are stored under the hash of the
+// original transaction, replacements are looked up in the hash index.
+func (j *Journal) get(txHash common.Hash) (*JournalEntry, error) {
+	var entry JournalEntry
+	err := j.store.Get(journalEntryPrefix+txHash.Hex(), &entry)
+	if err == nil {
+		return &entry, nil
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
+		return nil, err
+	}
+
+	var original common.Hash
+	err = j.store.Get(journalHashPrefix+txHash.Hex(), &original)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, ErrJournalEntryNotFound
+		}
+		return nil, err
+	}
+
+	err = j.store.Get(journalEntryPrefix+original.Hex(), &entry)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, ErrJournalEntryNotFound
+		}
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (j *Journal) put(entry *JournalEntry) error {
+	entry.Updated = time.Now().UTC()
+	return j.store.Put(journalEntryPrefix+entry.Hash.Hex(), entry)
+}
@@ -0,0 +1,97 @@
+package transaction
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+var (
+	// ErrNoPassphrase is returned when no passphrase source is configured.
+	ErrNoPassphrase = errors.New("no passphrase given")
+	// ErrPassphraseMismatch is returned when the confirmation differs from the passphrase.
+	ErrPassphraseMismatch = errors.New("passphrases do not match")
+)
+
+// PassphraseSource says where to read a keystore passphrase from. File is
+// used if set, then the environment variable Env if it is set, and if
+// neither is the user is prompted on the terminal.
+type PassphraseSource struct {
+	File    string // file whose first line is the passphrase
+	Env     string // environment variable holding the passphrase
+	Prompt  string // prompt for the terminal, empty to not prompt
+	Confirm bool   // ask twice when prompting, e.g. for new keys
+}
+
+// Passphrase reads the passphrase.
+func (s PassphraseSource) Passphrase() (string, error) {
+	if s.File != "" {
+		data, err := ioutil.ReadFile(s.File)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(strings.SplitN(string(data), "\n", 2)[0], "\r"), nil
+	}
+
+	if s.Env != "" {
+		if passphrase, ok := os.LookupEnv(s.Env); ok {
+			return passphrase, nil
+		}
+	}
+
+	if s.Prompt == "" {
+		return "", ErrNoPassphrase
+	}
+
+	passphrase, err := readPassword(s.Prompt)
+	if err != nil {
+		return "", err
+	}
+	if s.Confirm {
+		confirmation, err := readPassword("Repeat passphrase: ")
+		if err != nil {
+			return "", err
+		}
+		if confirmation != passphrase {
+			return "", ErrPassphraseMismatch
+		}
+	}
+	return passphrase, nil
+}
+
+// readPassword reads a line from the terminal without echoing it.
+func readPassword(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	password, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	return string(password), nil
+}
+
+// NewKeystoreSigner returns a signer for the key in the Web3 Secret Storage
+// (keystore v3) file at path, decrypted with the passphrase from source.
+func NewKeystoreSigner(path string, source PassphraseSource) (Signer, error) {
+	keyJSON, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	passphrase, err := source.Passphrase()
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := keystore.DecryptKey(keyJSON, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt %s: %w", path, err)
+	}
+
+	return NewDefaultSigner(key.PrivateKey), nil
+}
@@ -0,0 +1,381 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var (
+	// ErrNotTracked is returned for transactions the monitor does not know.
+	ErrNotTracked = errors.New("transaction not tracked")
+	// ErrNonceUsed is returned when another transaction with the same nonce was mined.
+	ErrNonceUsed = errors.New("nonce used by another transaction")
+	// ErrMonitorClosed is returned when waiting on a closed monitor.
+	ErrMonitorClosed = errors.New("monitor closed")
+)
+
+// finishedRetention is how long finished transactions stay available to WaitMined.
+const finishedRetention = time.Hour
+
+// priceBump is the minimum fee increase in percent nodes require to accept a
+// replacement transaction (the geth txpool default).
+const priceBump = 10
+
+// ReplacedFunc is called with the signed replacement of a transaction before
+// it is broadcast, so that it can be recorded durably first. If it returns an
+// error the replacement is not broadcast.
+type ReplacedFunc func(sender common.Address, replaced, replacement *types.Transaction) error
+
+type trackedTx struct {
+	sender   common.Address
+	signer   Signer
+	tx       *types.Transaction // latest broadcast version
+	hashes   []common.Hash      // all broadcast versions, the original first
+	lastSent time.Time
+	receipt  *types.Receipt
+	err      error
+	done     chan struct{}
+	finished time.Time
+}
+
+// TxMonitor watches sent transactions and replaces those which stay pending
+// longer than the threshold with the same nonce and bumped fees. Every
+// replacement hash is recorded so that waiting on the original hash learns
+// the final outcome.
+type TxMonitor struct {
+	backend   Backend
+	threshold time.Duration
+	interval  time.Duration
+	onReplace ReplacedFunc
+
+	// Debug prints replacements and failed checks.
+	Debug bool
+
+	mu     sync.Mutex
+	txs    map[common.Hash]*trackedTx // by every hash of the transaction
+	quit   chan struct{}
+	wg     sync.WaitGroup
+	closed bool
+}
+
+// NewTxMonitor creates a monitor which checks the tracked transactions every
+// interval and replaces those pending for longer than threshold. onReplace
+// may be nil.
+func NewTxMonitor(backend Backend, threshold time.Duration, interval time.Duration, onReplace ReplacedFunc) *TxMonitor {
+	return &TxMonitor{
+		backend:   backend,
+		threshold: threshold,
+		interval:  interval,
+		onReplace: onReplace,
+		txs:       make(map[common.Hash]*trackedTx),
+		quit:      make(chan struct{}),
+	}
+}
+
+// Start starts the background loop.
+func (m *TxMonitor) Start() {
+	m.wg.Add(1)
+	go m.loop()
+}
+
+// Close stops the background loop.
+func (m *TxMonitor) Close() error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil
+	}
+	m.closed = true
+	close(m.quit)
+	m.mu.Unlock()
+
+	m.wg.Wait()
+	return nil
+}
+
+// Track starts monitoring a transaction which was signed by signer for sender
+// and broadcast. previous are the hashes of earlier versions tx replaced, e.g.
+// when a replaced transaction is resumed after a restart, waiting on any of
+// them waits for tx.
+func (m *TxMonitor) Track(tx *types.Transaction, sender common.Address, signer Signer, previous ...common.Hash) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hashes := append(append([]common.Hash(nil), previous...), tx.Hash())
+	for _, hash := range hashes {
+		if _, ok := m.txs[hash]; ok {
+			return
+		}
+	}
+
+	t := &trackedTx{
+		sender:   sender,
+		signer:   signer,
+		tx:       tx,
+		hashes:   hashes,
+		lastSent: time.Now(),
+		done:     make(chan struct{}),
+	}
+	for _, hash := range hashes {
+		m.txs[hash] = t
+	}
+}
+
+// Replacements returns all hashes broadcast for the transaction, starting
+// with the original one.
+func (m *TxMonitor) Replacements(txHash common.Hash) ([]common.Hash, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.txs[txHash]
+	if !ok {
+		return nil, ErrNotTracked
+	}
+	return append([]common.Hash(nil), t.hashes...), nil
+}
+
+// AddReplacement records a replacement of a tracked transaction that was
+// broadcast by someone else than the monitor, e.g. a cancellation.
+func (m *TxMonitor) AddReplacement(txHash common.Hash, replacement *types.Transaction) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.txs[txHash]
+	if !ok {
+		return ErrNotTracked
+	}
+	t.tx = replacement
+	t.hashes = append(t.hashes, replacement.Hash())
+	t.lastSent = time.Now()
+	m.txs[replacement.Hash()] = t
+	return nil
+}
+
+// WaitMined waits until the transaction or one of its replacements is mined
+// and returns the receipt of the version that was mined.
+func (m *TxMonitor) WaitMined(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	m.mu.Lock()
+	t, ok := m.txs[txHash]
+	m.mu.Unlock()
+	if !ok {
+		return nil, ErrNotTracked
+	}
+
+	select {
+	case <-t.done:
+		return t.receipt, t.err
+	case <-m.quit:
+		return nil, ErrMonitorClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (m *TxMonitor) loop() {
+	defer m.wg.Done()
+
+	for {
+		select {
+		case <-time.After(m.interval):
+		case <-m.quit:
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), m.interval)
+		m.check(ctx)
+		cancel()
+	}
+}
+
+// check goes through all pending transactions once.
+func (m *TxMonitor) check(ctx context.Context) {
+	m.mu.Lock()
+	pending := make(map[*trackedTx]struct{})
+	for hash, t := range m.txs {
+		select {
+		case <-t.done:
+			if time.Since(t.finished) > finishedRetention {
+				delete(m.txs, hash)
+			}
+		default:
+			pending[t] = struct{}{}
+		}
+	}
+	m.mu.Unlock()
+
+	for t := range pending {
+		err := m.checkTx(ctx, t)
+		if err != nil && m.Debug {
+			fmt.Println("TxMonitor: check ", t.hashes[0], " failed: ", err)
+		}
+	}
+}
+
+func (m *TxMonitor) checkTx(ctx context.Context, t *trackedTx) error {
+	m.mu.Lock()
+	hashes := append([]common.Hash(nil), t.hashes...)
+	tx := t.tx
+	lastSent := t.lastSent
+	m.mu.Unlock()
+
+	for _, hash := range hashes {
+		receipt, err := m.backend.TransactionReceipt(ctx, hash)
+		if err != nil {
+			if errors.Is(err, ethereum.NotFound) {
+				continue
+			}
+			return err
+		}
+		if receipt != nil {
+			m.finish(t, receipt, nil)
+			return nil
+		}
+	}
+
+	confirmed, err := m.backend.NonceAt(ctx, t.sender, nil)
+	if err != nil {
+		return err
+	}
+	if confirmed > tx.Nonce() {
+		// the nonce is used but none of our receipts showed up, either another
+		// transaction took the nonce or a receipt raced with the nonce query
+		for _, hash := range hashes {
+			receipt, err := m.backend.TransactionReceipt(ctx, hash)
+			if err == nil && receipt != nil {
+				m.finish(t, receipt, nil)
+				return nil
+			}
+		}
+		m.finish(t, nil, ErrNonceUsed)
+		return nil
+	}
+
+	if time.Since(lastSent) < m.threshold {
+		return nil
+	}
+
+	replacement, err := BumpFees(ctx, m.backend, tx)
+	if err != nil {
+		return err
+	}
+
+	signedTx, err := t.signer.SignTx(replacement, tx.ChainId())
+	if err != nil {
+		return err
+	}
+
+	if m.onReplace != nil {
+		err = m.onReplace(t.sender, tx, signedTx)
+		if err != nil {
+			return err
+		}
+	}
+
+	// the replacement is waited for even if broadcasting fails, it may have
+	// reached the node anyway
+	m.mu.Lock()
+	t.tx = signedTx
+	t.hashes = append(t.hashes, signedTx.Hash())
+	t.lastSent = time.Now()
+	m.txs[signedTx.Hash()] = t
+	m.mu.Unlock()
+
+	err = m.backend.SendTransaction(ctx, signedTx)
+	if err != nil {
+		return err
+	}
+
+	if m.Debug {
+		fmt.Println("TxMonitor: replaced ", tx.Hash(), " with ", signedTx.Hash())
+	}
+	return nil
+}
+
+func (m *TxMonitor) finish(t *trackedTx, receipt *types.Receipt, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	select {
+	case <-t.done:
+		return
+	default:
+	}
+	t.receipt = receipt
+	t.err = err
+	t.finished = time.Now()
+	close(t.done)
+}
+
+// BumpFees returns an unsigned copy of tx with the same nonce and fees high
+// enough to replace it, i.e. at least priceBump percent above the old ones,
+// or the currently suggested fees if those are higher.
+func BumpFees(ctx context.Context, backend Backend, tx *types.Transaction) (*types.Transaction, error) {
+	return ReplaceWith(ctx, backend, tx, tx.To(), tx.Value(), tx.Gas(), tx.Data())
+}
+
+// ReplaceWith returns an unsigned transaction with the nonce of tx and the
+// given content which pays enough fees to replace tx.
+func ReplaceWith(ctx context.Context, backend Backend, tx *types.Transaction, to *common.Address, value *big.Int, gas uint64, data []byte) (*types.Transaction, error) {
+	if tx.Type() == types.DynamicFeeTxType {
+		gasTipCap := bump(tx.GasTipCap())
+		gasFeeCap := bump(tx.GasFeeCap())
+
+		suggestedTipCap, suggestedFeeCap, err := SuggestDynamicFees(ctx, backend)
+		if err != nil {
+			return nil, err
+		}
+		if suggestedTipCap.Cmp(gasTipCap) > 0 {
+			gasTipCap = suggestedTipCap
+		}
+		if suggestedFeeCap.Cmp(gasFeeCap) > 0 {
+			gasFeeCap = suggestedFeeCap
+		}
+		if gasFeeCap.Cmp(gasTipCap) < 0 {
+			gasFeeCap = gasTipCap
+		}
+
+		return types.NewTx(&types.DynamicFeeTx{
+			ChainID:   tx.ChainId(),
+			Nonce:     tx.Nonce(),
+			GasTipCap: gasTipCap,
+			GasFeeCap: gasFeeCap,
+			Gas:       gas,
+			To:        to,
+			Value:     value,
+			Data:      data,
+		}), nil
+	}
+
+	gasPrice := bump(tx.GasPrice())
+	suggested, err := backend.SuggestGasPrice(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if suggested.Cmp(gasPrice) > 0 {
+		gasPrice = suggested
+	}
+
+	return types.NewTx(&types.LegacyTx{
+		Nonce:    tx.Nonce(),
+		GasPrice: gasPrice,
+		Gas:      gas,
+		To:       to,
+		Value:    value,
+		Data:     data,
+	}), nil
+}
+
+// bump increases a fee by priceBump percent, rounded up.
+func bump(fee *big.Int) *big.Int {
+	bumped := new(big.Int).Mul(fee, big.NewInt(100+priceBump))
+	bumped.Add(bumped, big.NewInt(99))
+	return bumped.Div(bumped, big.NewInt(100))
+}
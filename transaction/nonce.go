@@ -0,0 +1,378 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"backend-demo/storage"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const nonceStatePrefix = "nonce_"
+
+// IsNonceTooLow returns true if the node rejected a transaction because its
+// nonce was already used, e.g. by another client of the same account.
+func IsNonceTooLow(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "nonce too low")
+}
+
+// IsAlreadyKnown returns true if the node rejected a transaction because it
+// already has the very same transaction.
+func IsAlreadyKnown(err error) bool {
+	return err != nil && (strings.Contains(err.Error(), "already known") || strings.Contains(err.Error(), "known transaction"))
+}
+
+// nonceState is the persisted part of the NonceManager.
+type nonceState struct {
+	Next     uint64                 // next fresh nonce
+	Released []uint64               // nonces below Next that were handed out but never broadcast
+	Sent     map[uint64]common.Hash // broadcast transactions the node does not count yet
+	Reserved map[uint64]time.Time   // nonces handed out by Reserve and when, neither committed nor released yet
+}
+
+// NonceManager hands out nonces for a single sender address, so that several
+// goroutines can send transactions for the same account concurrently without
+// waiting for the node to see the earlier ones. The state is persisted in the
+// store so nonces survive restarts. Nonces which were handed out but not used
+// are handed out again before fresh ones so that no gap blocks later
+// transactions. Before handing out a nonce the pending nonce of the node is
+// checked, if the account was used elsewhere or the node dropped a transaction
+// sent before, the state is reconciled with the chain first.
+// Nonces handed out by Next are held in memory, those handed out by Reserve
+// are persisted so they stay taken across restarts.
+type NonceManager struct {
+	mu       sync.Mutex
+	backend  Backend
+	store    storage.StateStorer
+	address  common.Address
+	loaded   bool
+	state    nonceState
+	inflight map[uint64]struct{} // handed out, neither committed nor released yet
+}
+
+// NewNonceManager creates a nonce manager for address.
+func NewNonceManager(backend Backend, store storage.StateStorer, address common.Address) *NonceManager {
+	return &NonceManager{
+		backend:  backend,
+		store:    store,
+		address:  address,
+		inflight: make(map[uint64]struct{}),
+	}
+}
+
+func (m *NonceManager) key() string {
+	return fmt.Sprintf("%s%x", nonceStatePrefix, m.address)
+}
+
+// Next returns the nonce to use for the next transaction. Every nonce must
+// be given back with either Commit or Release. Nonces which are not given
+// back before the process stops are handed out again after a restart.
+func (m *NonceManager) Next(ctx context.Context) (uint64, error) {
+	return m.next(ctx, false)
+}
+
+// Reserve returns the nonce to use for a transaction which is signed
+// elsewhere, e.g. offline. Unlike with Next the reservation is persisted, the
+// nonce is not handed out again until it is given back with Commit or
+// Release, also not by another process using the same store.
+func (m *NonceManager) Reserve(ctx context.Context) (uint64, error) {
+	return m.next(ctx, true)
+}
+
+func (m *NonceManager) next(ctx context.Context, reserve bool) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.loaded {
+		if err := m.load(); err != nil {
+			return 0, err
+		}
+		if err := m.reconcile(ctx); err != nil {
+			return 0, err
+		}
+		m.loaded = true
+	} else {
+		confirmed, err := m.backend.NonceAt(ctx, m.address, nil)
+		if err != nil {
+			return 0, err
+		}
+		pending, err := m.backend.PendingNonceAt(ctx, m.address)
+		if err != nil {
+			return 0, err
+		}
+		m.prune(confirmed)
+		if m.peek() < pending || m.isGap(pending) {
+			// the nonce we would hand out is already taken or the node lost one we sent
+			if err := m.reconcile(ctx); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	var nonce uint64
+	if len(m.state.Released) > 0 {
+		nonce = m.state.Released[0]
+		m.state.Released = m.state.Released[1:]
+	} else {
+		nonce = m.state.Next
+		m.state.Next++
+	}
+	if reserve {
+		m.state.Reserved[nonce] = time.Now().UTC()
+	} else {
+		m.inflight[nonce] = struct{}{}
+	}
+
+	if err := m.save(); err != nil {
+		m.unreserve(nonce)
+		m.release(nonce)
+		return 0, err
+	}
+	return nonce, nil
+}
+
+// Commit records that the transaction with nonce was broadcast. It may also
+// be called for nonces not handed out by Next, e.g. when a journaled
+// transaction is broadcast again after a restart.
+func (m *NonceManager) Commit(nonce uint64, txHash common.Hash) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.loaded {
+		// Next still reconciles with the chain on first use
+		if err := m.load(); err != nil {
+			return err
+		}
+	}
+
+	m.unreserve(nonce)
+	m.state.Sent[nonce] = txHash
+	for i, released := range m.state.Released {
+		if released == nonce {
+			m.state.Released = append(m.state.Released[:i], m.state.Released[i+1:]...)
+			break
+		}
+	}
+	if m.state.Next <= nonce {
+		m.state.Next = nonce + 1
+	}
+	return m.save()
+}
+
+// Release gives back a nonce that was not used, e.g. because signing or
+// broadcasting the transaction failed. It will be handed out again. Nonces
+// which are not handed out (anymore), e.g. because Resync found them used,
+// are ignored.
+func (m *NonceManager) Release(nonce uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.loaded {
+		// Next still reconciles with the chain on first use
+		if err := m.load(); err != nil {
+			return err
+		}
+	}
+
+	if !m.unreserve(nonce) {
+		return nil
+	}
+	m.release(nonce)
+	return m.save()
+}
+
+// Resync is called when the node rejected the transaction with nonce because
+// the nonce is too low. The nonce is not handed out again and the state is
+// reconciled with the chain, so that the next transactions get usable nonces.
+func (m *NonceManager) Resync(ctx context.Context, nonce uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.loaded {
+		if err := m.load(); err != nil {
+			return err
+		}
+		m.loaded = true
+	}
+
+	m.unreserve(nonce)
+	return m.reconcile(ctx)
+}
+
+// Reconcile compares the local state with the chain. Confirmed nonces are
+// forgotten, nonces used by other clients are skipped and gaps, i.e. nonces
+// below the next nonce which are neither pending nor in use, are queued to be
+// filled by the next transactions.
+func (m *NonceManager) Reconcile(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.loaded {
+		if err := m.load(); err != nil {
+			return err
+		}
+		m.loaded = true
+	}
+
+	return m.reconcile(ctx)
+}
+
+// Gaps returns the nonces that are currently queued to be reused.
+func (m *NonceManager) Gaps() []uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]uint64(nil), m.state.Released...)
+}
+
+func (m *NonceManager) reconcile(ctx context.Context) error {
+	confirmed, err := m.backend.NonceAt(ctx, m.address, nil)
+	if err != nil {
+		return err
+	}
+	pending, err := m.backend.PendingNonceAt(ctx, m.address)
+	if err != nil {
+		return err
+	}
+
+	m.prune(confirmed)
+	for nonce := range m.state.Reserved {
+		if nonce < confirmed {
+			// used by a transaction broadcast without us
+			delete(m.state.Reserved, nonce)
+		}
+	}
+
+	// somebody else sent transactions from this account
+	if m.state.Next < pending {
+		m.state.Next = pending
+	}
+	if m.state.Next < confirmed {
+		m.state.Next = confirmed
+	}
+
+	var gaps []uint64
+	for nonce := confirmed; nonce < m.state.Next; nonce++ {
+		if _, ok := m.inflight[nonce]; ok {
+			continue
+		}
+		if _, ok := m.state.Reserved[nonce]; ok {
+			continue
+		}
+
+		txHash, ok := m.state.Sent[nonce]
+		if ok {
+			_, _, err := m.backend.TransactionByHash(ctx, txHash)
+			if err == nil {
+				continue
+			}
+			if !errors.Is(err, ethereum.NotFound) {
+				return err
+			}
+			// the transaction was dropped by the node
+			delete(m.state.Sent, nonce)
+		} else if nonce < pending {
+			// pending in the node although we have no record of it
+			continue
+		}
+
+		gaps = append(gaps, nonce)
+	}
+	m.state.Released = gaps
+	m.compact()
+
+	return m.save()
+}
+
+// peek returns the nonce Next would hand out.
+func (m *NonceManager) peek() uint64 {
+	if len(m.state.Released) > 0 {
+		return m.state.Released[0]
+	}
+	return m.state.Next
+}
+
+// isGap returns true if the pending nonce of the node is one we handed out
+// before, which is neither being used right now nor queued to be handed out
+// again. The transaction sent with it was dropped by the node, so all later
+// ones are stuck until it is filled.
+func (m *NonceManager) isGap(pending uint64) bool {
+	if pending >= m.state.Next {
+		return false
+	}
+	if _, ok := m.inflight[pending]; ok {
+		return false
+	}
+	if _, ok := m.state.Reserved[pending]; ok {
+		return false
+	}
+	for _, released := range m.state.Released {
+		if released == pending {
+			return false
+		}
+	}
+	return true
+}
+
+// prune forgets the sent transactions below nonce. Only transactions which
+// are not mined yet can still be dropped by the node.
+func (m *NonceManager) prune(nonce uint64) {
+	for sent := range m.state.Sent {
+		if sent < nonce {
+			delete(m.state.Sent, sent)
+		}
+	}
+}
+
+// unreserve removes nonce from the handed out nonces and returns true if it was handed out.
+func (m *NonceManager) unreserve(nonce uint64) bool {
+	if _, ok := m.inflight[nonce]; ok {
+		delete(m.inflight, nonce)
+		return true
+	}
+	if _, ok := m.state.Reserved[nonce]; ok {
+		delete(m.state.Reserved, nonce)
+		return true
+	}
+	return false
+}
+
+func (m *NonceManager) release(nonce uint64) {
+	m.state.Released = append(m.state.Released, nonce)
+	sort.Slice(m.state.Released, func(i, j int) bool { return m.state.Released[i] < m.state.Released[j] })
+	m.compact()
+}
+
+// compact lowers Next over released nonces directly below it, those do not
+// need to be remembered as gaps.
+func (m *NonceManager) compact() {
+	for len(m.state.Released) > 0 && m.state.Released[len(m.state.Released)-1]+1 == m.state.Next {
+		m.state.Released = m.state.Released[:len(m.state.Released)-1]
+		m.state.Next--
+	}
+}
+
+func (m *NonceManager) load() error {
+	err := m.store.Get(m.key(), &m.state)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+	if m.state.Sent == nil {
+		m.state.Sent = make(map[uint64]common.Hash)
+	}
+	if m.state.Reserved == nil {
+		m.state.Reserved = make(map[uint64]time.Time)
+	}
+	return nil
+}
+
+func (m *NonceManager) save() error {
+	return m.store.Put(m.key(), &m.state)
+}
@@ -0,0 +1,264 @@
+package transaction
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"backend-demo/storage"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// nonceBackend is a node which only knows the nonces of one account and
+// which of its transactions it has.
+type nonceBackend struct {
+	Backend
+	confirmed uint64
+	pending   uint64
+	known     map[common.Hash]bool
+}
+
+func (b *nonceBackend) NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error) {
+	return b.confirmed, nil
+}
+
+func (b *nonceBackend) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	return b.pending, nil
+}
+
+func (b *nonceBackend) TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	if !b.known[hash] {
+		return nil, false, ethereum.NotFound
+	}
+	return nil, true, nil
+}
+
+// send records that the node got the transaction txHash with nonce.
+func (b *nonceBackend) send(nonce uint64, txHash common.Hash) {
+	b.known[txHash] = true
+	if b.pending == nonce {
+		b.pending++
+	}
+}
+
+func mustNext(t *testing.T, m *NonceManager, want uint64) {
+	t.Helper()
+
+	nonce, err := m.Next(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nonce != want {
+		t.Fatalf("got nonce %d, want %d", nonce, want)
+	}
+}
+
+// mustSend hands out the next nonce, expecting want, and broadcasts a transaction with it.
+func mustSend(t *testing.T, m *NonceManager, b *nonceBackend, want uint64) common.Hash {
+	t.Helper()
+
+	mustNext(t, m, want)
+	txHash := common.BigToHash(new(big.Int).SetUint64(want + 1))
+	b.send(want, txHash)
+	if err := m.Commit(want, txHash); err != nil {
+		t.Fatal(err)
+	}
+	return txHash
+}
+
+func TestNonceManager(t *testing.T) {
+	address := common.HexToAddress("0xabcd")
+
+	for _, tc := range []struct {
+		name    string
+		backend nonceBackend
+		run     func(t *testing.T, m *NonceManager, b *nonceBackend, store storage.StateStorer)
+	}{
+		{
+			name:    "fresh nonces from the node",
+			backend: nonceBackend{confirmed: 5, pending: 7},
+			run: func(t *testing.T, m *NonceManager, b *nonceBackend, store storage.StateStorer) {
+				mustNext(t, m, 7)
+				mustNext(t, m, 8)
+			},
+		},
+		{
+			name: "released nonce is handed out again",
+			run: func(t *testing.T, m *NonceManager, b *nonceBackend, store storage.StateStorer) {
+				mustNext(t, m, 0)
+				mustNext(t, m, 1)
+				mustNext(t, m, 2)
+				if err := m.Release(1); err != nil {
+					t.Fatal(err)
+				}
+				if gaps := m.Gaps(); !reflect.DeepEqual(gaps, []uint64{1}) {
+					t.Fatalf("got gaps %v, want [1]", gaps)
+				}
+				mustNext(t, m, 1)
+				mustNext(t, m, 3)
+			},
+		},
+		{
+			name: "released nonces below next are compacted",
+			run: func(t *testing.T, m *NonceManager, b *nonceBackend, store storage.StateStorer) {
+				mustNext(t, m, 0)
+				mustNext(t, m, 1)
+				mustNext(t, m, 2)
+				for _, nonce := range []uint64{1, 2} {
+					if err := m.Release(nonce); err != nil {
+						t.Fatal(err)
+					}
+				}
+				mustNext(t, m, 1)
+			},
+		},
+		{
+			name: "release of a nonce not handed out is ignored",
+			run: func(t *testing.T, m *NonceManager, b *nonceBackend, store storage.StateStorer) {
+				mustNext(t, m, 0)
+				if err := m.Release(5); err != nil {
+					t.Fatal(err)
+				}
+				mustNext(t, m, 1)
+			},
+		},
+		{
+			name:    "commit of a nonce not handed out",
+			backend: nonceBackend{confirmed: 4, pending: 4},
+			run: func(t *testing.T, m *NonceManager, b *nonceBackend, store storage.StateStorer) {
+				txHash := common.HexToHash("0x01")
+				b.send(4, txHash)
+				if err := m.Commit(4, txHash); err != nil {
+					t.Fatal(err)
+				}
+				mustNext(t, m, 5)
+			},
+		},
+		{
+			name: "reservation survives a restart",
+			run: func(t *testing.T, m *NonceManager, b *nonceBackend, store storage.StateStorer) {
+				nonce, err := m.Reserve(context.Background())
+				if err != nil {
+					t.Fatal(err)
+				}
+				if nonce != 0 {
+					t.Fatalf("got reserved nonce %d, want 0", nonce)
+				}
+
+				restarted := NewNonceManager(b, store, address)
+				mustNext(t, restarted, 1)
+
+				if err := restarted.Release(0); err != nil {
+					t.Fatal(err)
+				}
+				mustNext(t, restarted, 0)
+			},
+		},
+		{
+			name: "unused nonce is handed out again after a restart",
+			run: func(t *testing.T, m *NonceManager, b *nonceBackend, store storage.StateStorer) {
+				mustSend(t, m, b, 0)
+				mustNext(t, m, 1)
+				mustSend(t, m, b, 2)
+
+				restarted := NewNonceManager(b, store, address)
+				mustNext(t, restarted, 1)
+				mustNext(t, restarted, 3)
+			},
+		},
+		{
+			name: "foreign transactions are skipped",
+			run: func(t *testing.T, m *NonceManager, b *nonceBackend, store storage.StateStorer) {
+				mustSend(t, m, b, 0)
+				// another client sent nonces 1 and 2
+				b.send(1, common.HexToHash("0xf1"))
+				b.send(2, common.HexToHash("0xf2"))
+				mustNext(t, m, 3)
+			},
+		},
+		{
+			name: "transaction dropped while running",
+			run: func(t *testing.T, m *NonceManager, b *nonceBackend, store storage.StateStorer) {
+				dropped := mustSend(t, m, b, 0)
+				mustSend(t, m, b, 1)
+
+				// the node forgets nonce 0, nonce 1 is queued behind the gap
+				delete(b.known, dropped)
+				b.pending = 0
+
+				mustNext(t, m, 0)
+				mustNext(t, m, 2)
+			},
+		},
+		{
+			name: "nonce in flight is no gap",
+			run: func(t *testing.T, m *NonceManager, b *nonceBackend, store storage.StateStorer) {
+				mustNext(t, m, 0)
+				if err := m.Reconcile(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+				mustNext(t, m, 1)
+			},
+		},
+		{
+			name: "dropped nonces are found on restart",
+			run: func(t *testing.T, m *NonceManager, b *nonceBackend, store storage.StateStorer) {
+				dropped := mustSend(t, m, b, 0)
+				mustSend(t, m, b, 1)
+				mustSend(t, m, b, 2)
+
+				delete(b.known, dropped)
+				b.pending = 0
+
+				restarted := NewNonceManager(b, store, address)
+				if err := restarted.Reconcile(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+				if gaps := restarted.Gaps(); !reflect.DeepEqual(gaps, []uint64{0}) {
+					t.Fatalf("got gaps %v, want [0]", gaps)
+				}
+				mustNext(t, restarted, 0)
+				mustNext(t, restarted, 3)
+			},
+		},
+		{
+			name: "confirmed nonces are forgotten",
+			run: func(t *testing.T, m *NonceManager, b *nonceBackend, store storage.StateStorer) {
+				mustSend(t, m, b, 0)
+				mustSend(t, m, b, 1)
+				b.confirmed = 2
+				b.known = make(map[common.Hash]bool)
+
+				mustNext(t, m, 2)
+				if len(m.state.Sent) != 0 {
+					t.Fatalf("got sent transactions %v, want none", m.state.Sent)
+				}
+			},
+		},
+		{
+			name: "resync after nonce too low",
+			run: func(t *testing.T, m *NonceManager, b *nonceBackend, store storage.StateStorer) {
+				mustNext(t, m, 0)
+				// another client used nonce 0 meanwhile
+				b.send(0, common.HexToHash("0xf0"))
+				if err := m.Resync(context.Background(), 0); err != nil {
+					t.Fatal(err)
+				}
+				mustNext(t, m, 1)
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			backend := tc.backend
+			backend.known = make(map[common.Hash]bool)
+			store := storage.NewMemStore()
+			m := NewNonceManager(&backend, store, address)
+
+			tc.run(t, m, &backend, store)
+		})
+	}
+}
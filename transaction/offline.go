@@ -0,0 +1,103 @@
+package transaction
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var (
+	// ErrWrongSigner is returned when signing an unsigned transaction with the key of another account.
+	ErrWrongSigner = errors.New("signer is not the sender of the transaction")
+	// ErrChainIDMismatch is returned when the transaction was built for another chain.
+	ErrChainIDMismatch = errors.New("chain id mismatch")
+)
+
+// UnsignedTx is a transaction that was built, but not signed, so it can be
+// carried to another machine for signing.
+type UnsignedTx struct {
+	ChainID     *big.Int
+	From        common.Address
+	Nonce       uint64
+	Description string
+	Tx          *types.Transaction
+}
+
+// unsignedTxJSON is the exported form of UnsignedTx. The transaction is kept
+// in its binary encoding as unsigned transactions cannot be decoded from json.
+type unsignedTxJSON struct {
+	ChainID     *big.Int
+	From        common.Address
+	Nonce       uint64
+	Description string
+	To          *common.Address `json:",omitempty"` // for review only, Tx is authoritative
+	Value       *big.Int        // for review only
+	Tx          hexutil.Bytes
+}
+
+// MarshalJSON implements json.Marshaler.
+func (u *UnsignedTx) MarshalJSON() ([]byte, error) {
+	raw, err := u.Tx.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&unsignedTxJSON{
+		ChainID:     u.ChainID,
+		From:        u.From,
+		Nonce:       u.Nonce,
+		Description: u.Description,
+		To:          u.Tx.To(),
+		Value:       u.Tx.Value(),
+		Tx:          raw,
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (u *UnsignedTx) UnmarshalJSON(data []byte) error {
+	var v unsignedTxJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(v.Tx); err != nil {
+		return err
+	}
+	if tx.Nonce() != v.Nonce {
+		return fmt.Errorf("nonce %d does not match transaction nonce %d", v.Nonce, tx.Nonce())
+	}
+
+	u.ChainID = v.ChainID
+	u.From = v.From
+	u.Nonce = v.Nonce
+	u.Description = v.Description
+	u.Tx = tx
+	return nil
+}
+
+// Sign signs the transaction. The signer has to be the account the
+// transaction was built for.
+func (u *UnsignedTx) Sign(signer Signer) (*types.Transaction, error) {
+	address, err := signer.EthereumAddress()
+	if err != nil {
+		return nil, err
+	}
+	if address != u.From {
+		return nil, ErrWrongSigner
+	}
+	if u.ChainID == nil || (u.Tx.Type() != types.LegacyTxType && u.Tx.ChainId().Cmp(u.ChainID) != 0) {
+		return nil, ErrChainIDMismatch
+	}
+
+	return signer.SignTx(u.Tx, u.ChainID)
+}
+
+// SignedTxSender returns the account which signed tx.
+func SignedTxSender(tx *types.Transaction) (common.Address, error) {
+	return types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+}
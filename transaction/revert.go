@@ -0,0 +1,140 @@
+package transaction
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// revertMessage is the error message nodes return for reverted calls.
+const revertMessage = "execution reverted"
+
+// panicSelector is the selector of Panic(uint256), the revert payload of
+// failed asserts, arithmetic overflows and similar since solidity 0.8.
+var panicSelector = []byte{0x4e, 0x48, 0x7b, 0x71}
+
+// panicReasons describes the panic codes solidity uses.
+var panicReasons = map[uint64]string{
+	0x00: "generic panic",
+	0x01: "assert failed",
+	0x11: "arithmetic underflow or overflow",
+	0x12: "division or modulo by zero",
+	0x21: "invalid enum value",
+	0x22: "invalid storage byte array",
+	0x31: "pop on empty array",
+	0x32: "array index out of bounds",
+	0x41: "out of memory",
+	0x51: "call to zero-initialized function",
+}
+
+// RevertError is a reverted call. Reason is set if the contract reverted
+// with Error(string), Data holds the raw payload, e.g. a custom error. If
+// the reason is known, Unwrap returns the matching error so callers can use
+// errors.Is.
+type RevertError struct {
+	Reason string
+	Data   []byte
+	Err    error // known error for Reason, nil if there is none
+}
+
+func (e *RevertError) Error() string {
+	if e.Reason != "" {
+		return revertMessage + ": " + e.Reason
+	}
+	if len(e.Data) > 0 {
+		return revertMessage + ": " + hexutil.Encode(e.Data)
+	}
+	return revertMessage
+}
+
+func (e *RevertError) Unwrap() error {
+	return e.Err
+}
+
+// PanicError is a call that reverted with Panic(uint256).
+type PanicError struct {
+	Code *big.Int
+}
+
+func (e *PanicError) Error() string {
+	reason, ok := panicReasons[e.Code.Uint64()]
+	if !ok || !e.Code.IsUint64() {
+		reason = "unknown panic"
+	}
+	return fmt.Sprintf("%s: panic 0x%x (%s)", revertMessage, e.Code, reason)
+}
+
+// DecodeError turns the error of eth_call or eth_estimateGas into a
+// *RevertError or *PanicError if the execution reverted and returns other
+// errors unchanged. known maps revert reasons to the errors RevertError.Err
+// is set to, it may be nil.
+func DecodeError(err error, known map[string]error) error {
+	var revertErr *RevertError
+	var panicErr *PanicError
+	if errors.As(err, &revertErr) || errors.As(err, &panicErr) || !IsRevert(err) {
+		return err
+	}
+
+	data, _ := RevertData(err)
+	if len(data) == 4+32 && bytes.Equal(data[:4], panicSelector) {
+		return &PanicError{Code: new(big.Int).SetBytes(data[4:])}
+	}
+
+	revertErr = &RevertError{Data: data}
+	if reason, err := abi.UnpackRevert(data); err == nil {
+		revertErr.Reason = reason
+		revertErr.Err = known[reason]
+	}
+	return revertErr
+}
+
+// IsRevert returns true if err, returned by eth_call or eth_estimateGas,
+// reports that the execution reverted.
+func IsRevert(err error) bool {
+	if err == nil {
+		return false
+	}
+	if _, ok := RevertData(err); ok {
+		return true
+	}
+	return strings.Contains(err.Error(), revertMessage)
+}
+
+// RevertData returns the revert payload attached to an eth_call or
+// eth_estimateGas error, if the node sent one.
+func RevertData(err error) ([]byte, bool) {
+	var dataErr rpc.DataError
+	if !errors.As(err, &dataErr) {
+		return nil, false
+	}
+
+	hex, ok := dataErr.ErrorData().(string)
+	if !ok {
+		return nil, false
+	}
+	data, err := hexutil.Decode(hex)
+	if err != nil || len(data) == 0 {
+		return nil, false
+	}
+	return data, true
+}
+
+// RevertReason returns the Error(string) reason of a revert error, or an
+// empty string if it has none.
+func RevertReason(err error) string {
+	data, ok := RevertData(err)
+	if !ok {
+		return ""
+	}
+	reason, err := abi.UnpackRevert(data)
+	if err != nil {
+		return ""
+	}
+	return reason
+}